@@ -1,6 +1,10 @@
 package ctl
 
 import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +32,447 @@ func TestSimpleModel(t *testing.T) {
 		t.Error("expected two steps")
 	}
 }
+
+// TestEnumPhases tests a protocol with symbolic phases in both encodings.
+func TestEnumPhases(t *testing.T) {
+	for _, encoding := range []Encoding{Binary, OneHot} {
+		m := NewModel()
+		p := m.EnumEncoded("phase", encoding, "idle", "req", "ack", "done")
+		retry := m.Bool("retry")
+
+		// idle -> req -> ack -> done -> idle, and a request can be retried.
+		steps := [][]string{{"idle", "req"}, {"req", "ack"}, {"ack", "done"},
+			{"done", "idle"}, {"ack", "req"}}
+		for _, step := range steps {
+			m.Add(p.Is(step[0]),
+				p.Next().Is(step[1]).And(retry.Next().Eq(p.Is("ack").And(p.Next().Is("req")))))
+		}
+
+		init := p.Is("idle").And(retry.Neg())
+		sets := m.EF(p.Is("done"))
+		if LeastSteps(init, sets) != 3 {
+			t.Error("expected three steps")
+		}
+
+		// Check that the phases are decoded.
+		example := GenerateExample(m, init, sets)
+		phases := []string{"idle", "req", "ack", "done"}
+		if len(example) != len(phases) {
+			t.Fatal("unexpected example length")
+		}
+		for i, state := range example {
+			if state.enums["phase"] != phases[i] {
+				t.Errorf("expected phase %v", phases[i])
+			}
+		}
+		table := convertStatesToTable(example)
+		if table[0][1] != "phase" || table[2][1] != "req" {
+			t.Error("unexpected table")
+		}
+
+		// A retry is only possible after an acknowledgement.
+		if !p.Eq(p).Contains(p.Domain()) || m.EX(p.Is("req"), retry) != False {
+			t.Error("unexpected retry")
+		}
+	}
+}
+
+// TestMutualExclusion tests cardinality constraints on a simple mutex.
+func TestMutualExclusion(t *testing.T) {
+	m := NewModel()
+	crit := []*BDD{m.Bool("c0"), m.Bool("c1"), m.Bool("c2")}
+
+	// A process can enter the critical section if it is empty, and leave it.
+	for i, c := range crit {
+		others := True
+		for j, d := range crit {
+			if i != j {
+				others = others.And(d.Next().Eq(d))
+			}
+		}
+		m.Add(ExactlyK(crit, 0), c.Next().Eq(True).And(others))
+		m.Add(c, c.Next().Eq(False).And(others))
+	}
+
+	// At most one process is ever in the critical section.
+	init := AtMostK(crit, 0)
+	if LeastSteps(init, m.EF(AtLeastK(crit, 2))) != -1 {
+		t.Error("expected mutual exclusion")
+	}
+	if LeastSteps(init, m.EF(Count(crit).Eq(Int(1)))) != 1 {
+		t.Error("expected one step")
+	}
+
+	// Check counts.
+	if Count(crit).Leq(Int(3)) != True || AtMostK(crit, 3) != True ||
+		!ExactlyK(crit, 2).Equals(Count(crit).Eq(Int(2))) {
+		t.Error("unexpected count")
+	}
+}
+
+// TestUniversal tests the universal operators in a model with a deadlock.
+func TestUniversal(t *testing.T) {
+	m := NewModel()
+	a := m.Bool("a")
+	b := m.Bool("b")
+
+	// !a!b -> a!b (deadlock), !a!b -> ab -> ab, !ab -> !a!b
+	s0, s1 := a.Neg().And(b.Neg()), a.And(b.Neg())
+	s2, s3 := a.And(b), a.Neg().And(b)
+	m.Add(s0, a.Next())
+	m.Add(s2, a.Next().And(b.Next()))
+	m.Add(s3, a.Next().Neg().And(b.Next().Neg()))
+
+	// The deadlock state s1 satisfies AX b only with finite paths.
+	ax, _ := m.AX(True, b)
+	if ax != s1.Or(s2) {
+		t.Error("unexpected AX with finite paths")
+	}
+	m.SetDeadlockPolicy(SelfLoops)
+	ax, _ = m.AX(True, b)
+	if ax != s2 {
+		t.Error("unexpected AX with self loops")
+	}
+
+	// Both policies agree on AF, AG and AU.
+	for _, policy := range []DeadlockPolicy{FinitePaths, SelfLoops} {
+		m.SetDeadlockPolicy(policy)
+		af, _ := m.AF(b)
+		ag, _ := m.AG(a)
+		au, _ := m.AU(b.Neg(), a.And(b))
+		ar, _ := m.AR(b, a.Or(b))
+		er, _ := m.ER(False, b.Neg())
+		if af[len(af)-1] != b || len(af) != 1 {
+			t.Error("unexpected AF")
+		}
+		if ag[len(ag)-1] != s1.Or(s2) || !ag[len(ag)-1].Equals(m.EF(a.Neg())[len(m.EF(a.Neg()))-1].Neg()) {
+			t.Error("unexpected AG")
+		}
+		if au[len(au)-1] != s2 {
+			t.Error("unexpected AU")
+		}
+		if ar[len(ar)-1] != s1.Or(s2).Or(s3) {
+			t.Error("unexpected AR")
+		}
+		if er[len(er)-1] != s0.Or(s1) {
+			t.Error("unexpected ER")
+		}
+	}
+
+	// Deadlocks can also be rejected.
+	m.SetDeadlockPolicy(FailOnDeadlock)
+	if _, err := m.AF(b); err == nil || err.(*DeadlockError).States != s1 {
+		t.Error("expected a deadlock error")
+	}
+
+	// Only deadlocks that can affect the result are rejected.
+	if _, err := m.AF(a); err != nil {
+		t.Error("unexpected deadlock error")
+	}
+	if _, err := m.AG(a.Neg()); err != nil {
+		t.Error("unexpected deadlock error")
+	}
+}
+
+// TestDeadlocks tests deadlock detection.
+func TestDeadlocks(t *testing.T) {
+	m := NewModel()
+	a := m.Bool("a")
+	b := m.Bool("b")
+
+	// !a!b -> !ab -> ab -> a!b (deadlock), and !a!b -> ab
+	m.Add(a.Neg().And(b.Neg()), a.Next().Neg().And(b.Next()))
+	m.Add(a.Neg().And(b.Neg()), a.Next().And(b.Next()))
+	m.Add(a.Neg().And(b), a.Next().And(b.Next()))
+	m.Add(a.And(b), a.Next().And(b.Next().Neg()))
+
+	dead := a.And(b.Neg())
+	if m.Deadlocks() != dead {
+		t.Error("unexpected deadlocks")
+	}
+	if m.ReachableDeadlocks(a.Neg().And(b.Neg())) != dead ||
+		m.ReachableDeadlocks(dead.Neg()) != dead ||
+		m.ReachableDeadlocks(False) != False {
+		t.Error("unexpected reachable deadlocks")
+	}
+
+	// The shortest path skips !ab.
+	path := DeadlockExample(m, a.Neg().And(b.Neg()))
+	if len(path) != 3 || !path[1].bools["a"] || !path[1].bools["b"] {
+		t.Error("unexpected deadlock example")
+	}
+	if len(DeadlockExample(m, False)) != 0 {
+		t.Error("unexpected deadlock example")
+	}
+}
+
+// TestDot tests the DOT output of BDDs with shared nodes.
+func TestDot(t *testing.T) {
+	m := NewModel()
+	a := m.Bool("a")
+	b := m.Bool("b")
+
+	var out strings.Builder
+	err := WriteDotRoots(&out, []DotRoot{{"and", a.And(b)}, {"b", b}}, DotOptions{})
+	expected := `digraph "bdd" {
+  r0 [label="and", shape=box];
+  r0 -> n0;
+  r1 [label="b", shape=box];
+  r1 -> n1;
+  T [label="1", shape=box];
+  F [label="0", shape=box];
+  { rank=same; n0 [label="a"]; }
+  { rank=same; n1 [label="b"]; }
+  n0 -> n1;
+  n0 -> F [style=dashed];
+  n1 -> T;
+  n1 -> F [style=dashed];
+}
+`
+	if err != nil || out.String() != expected {
+		t.Errorf("unexpected output:\n%v", out.String())
+	}
+
+	// Next variables are labelled with their name, and bits are grouped.
+	i := m.Int("i", 3)
+	out.Reset()
+	WriteDot(&out, i.Next().Eq(i), DotOptions{"i", []*Integer{i}})
+	if !strings.Contains(out.String(), `label="next(i@1)"`) ||
+		!strings.Contains(out.String(), `subgraph "cluster_i"`) {
+		t.Errorf("unexpected output:\n%v", out.String())
+	}
+
+	// Variables that are not ordered stay that way.
+	x, y := &Variable{Name: "x"}, &Variable{Name: "y"}
+	out.Reset()
+	WriteDot(&out, Node(x, Node(y, True, False), False), DotOptions{})
+	if x.ordered() || y.ordered() || !strings.Contains(out.String(), `n1 [label="y"]`) {
+		t.Errorf("unexpected output:\n%v", out.String())
+	}
+}
+
+// TestExportGraph tests the state graph of a small model.
+func TestExportGraph(t *testing.T) {
+	m := NewModel()
+	c := m.Int("c", 2)
+	done := m.Bool("done")
+	m.AddRule("inc", c.Lt(Int(2)), c.Next().Eq(c.Add(Int(1))).And(done.Next().Eq(done)))
+	m.AddRule("stop", c.Eq(Int(2)).And(done.Neg()), c.Next().Eq(c).And(done.Next()))
+	init := c.Eq(Int(0)).And(done.Neg())
+
+	// There are 4 states and 3 transitions, and the last state is a deadlock.
+	var out strings.Builder
+	err := m.ExportGraph(init, &out, JSON, Highlight{"deadlock", m.Deadlocks()})
+	var graph struct {
+		States []struct {
+			Init       bool
+			Values     map[string]interface{}
+			Highlights []string
+		}
+		Edges []struct {
+			From, To int
+			Rule     string
+		}
+	}
+	if err != nil || json.Unmarshal([]byte(out.String()), &graph) != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(graph.States) != 4 || len(graph.Edges) != 3 {
+		t.Fatalf("expected 4 states and 3 edges:\n%v", out.String())
+	}
+	for i, s := range graph.States {
+		if s.Init != (i == 0) || (len(s.Highlights) == 1) != (i == 3) {
+			t.Errorf("unexpected state %v", s)
+		}
+	}
+	if e := graph.Edges[2]; e.From != 2 || e.To != 3 || e.Rule != "stop" {
+		t.Errorf("unexpected edge %v", e)
+	}
+
+	// The other formats contain the same edges.
+	out.Reset()
+	m.ExportGraph(init, &out, DOT)
+	if !strings.Contains(out.String(), `s2 -> s3 [label="stop"];`) {
+		t.Errorf("unexpected DOT output:\n%v", out.String())
+	}
+	out.Reset()
+	m.ExportGraph(init, &out, GraphML)
+	if !strings.Contains(out.String(), `<edge source="s2" target="s3">`) {
+		t.Errorf("unexpected GraphML output:\n%v", out.String())
+	}
+}
+
+// TestSaveLoad tests that a saved model behaves the same after loading.
+func TestSaveLoad(t *testing.T) {
+	m := NewModel()
+	c := m.SignedInt("c", -2, 2)
+	phase := m.Enum("phase", "up", "down")
+	m.AddRule("up", phase.Is("up").And(c.Lt(Signed(2))),
+		c.Next().Eq(c.Add(Int(1))).And(phase.Next().Eq(phase)))
+	m.AddRule("down", phase.Is("down").And(Signed(-2).Lt(c)),
+		c.Next().Eq(c.Sub(Int(1))).And(phase.Next().Eq(phase)))
+	m.Add(True, c.Next().Eq(c).And(phase.Next().Is("down")))
+	m.SetInit(c.Eq(Int(0)).And(phase.Is("up")))
+
+	var expected strings.Builder
+	m.ExportGraph(m.Init(), &expected, JSON)
+	for _, format := range []FileFormat{BinaryFormat, TextFormat} {
+		var file strings.Builder
+		if err := m.Save(&file, format); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := Load(strings.NewReader(file.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var graph strings.Builder
+		loaded.ExportGraph(loaded.Init(), &graph, JSON)
+		if graph.String() != expected.String() {
+			t.Errorf("unexpected graph after loading:\n%v", graph.String())
+		}
+
+		// Any change is detected.
+		data := []byte(file.String())
+		data[len(data)/2] ^= 1
+		if _, err := Load(strings.NewReader(string(data))); !errors.Is(err, ErrInvalidFile) {
+			t.Errorf("expected invalid file, got %v", err)
+		}
+		if _, err := Load(strings.NewReader(file.String()[:len(data)-3])); !errors.Is(err, ErrInvalidFile) {
+			t.Errorf("expected invalid file, got %v", err)
+		}
+
+		// A huge count (the number of values of the enum) is not allocated.
+		count, huge := ` 2 "up"`, ` 1073741824 "up"`
+		if format == BinaryFormat {
+			count, huge = "\x04\x02up", string(binary.AppendVarint(nil, 1<<30))+"\x02up"
+		}
+		corrupt := strings.Replace(file.String(), count, huge, 1)
+		if corrupt == file.String() {
+			t.Error("expected the enum values in the file")
+		}
+		if _, err := Load(strings.NewReader(corrupt)); !errors.Is(err, ErrInvalidFile) {
+			t.Errorf("expected invalid file, got %v", err)
+		}
+
+		// Invalid positions in the ordering are detected (such as two variables
+		// with the same position, or positions beyond the number of variables).
+		for _, seq := range []func(v *Variable) uint{
+			func(v *Variable) uint { return min(v.seq, 3) },
+			func(v *Variable) uint { return v.seq + uint(2*len(m.vars)) },
+		} {
+			seqs := make([]uint, len(m.vars))
+			for i, v := range m.vars {
+				seqs[i] = v.seq
+				v.seq, v.twin.seq = seq(v), seq(v)+1
+			}
+			file.Reset()
+			m.Save(&file, format)
+			for i, v := range m.vars {
+				v.seq, v.twin.seq = seqs[i], seqs[i]+1
+			}
+			if _, err := Load(strings.NewReader(file.String())); !errors.Is(err, ErrInvalidFile) {
+				t.Errorf("expected invalid file, got %v", err)
+			}
+		}
+	}
+
+	// The ordering is kept after reordering.
+	Reorder()
+	var file strings.Builder
+	m.Save(&file, BinaryFormat)
+	loaded, err := Load(strings.NewReader(file.String()))
+	var graph strings.Builder
+	if err == nil {
+		loaded.ExportGraph(loaded.Init(), &graph, JSON)
+	}
+	if err != nil || graph.String() != expected.String() {
+		t.Errorf("unexpected graph after reordering (%v)", err)
+	}
+
+	// BDDs can be read by a model with the same variables.
+	file.Reset()
+	m.WriteBDDs(&file, TextFormat, m.Init(), m.Domain(), False)
+	bdds, err := m.ReadBDDs(strings.NewReader(file.String()))
+	if err != nil || len(bdds) != 3 || bdds[0] != m.Init() || bdds[1] != m.Domain() || bdds[2] != False {
+		t.Errorf("unexpected BDDs (%v)", err)
+	}
+	if _, err := NewModel().ReadBDDs(strings.NewReader(file.String())); !errors.Is(err, ErrInvalidFile) {
+		t.Errorf("expected invalid file, got %v", err)
+	}
+	lines := strings.Split(file.String(), "\n")
+	lines[len(lines)-3] = strings.Replace(lines[len(lines)-3], "3 ", "1073741824 ", 1)
+	if _, err := m.ReadBDDs(strings.NewReader(strings.Join(lines, "\n"))); !errors.Is(err, ErrInvalidFile) {
+		t.Errorf("expected invalid file, got %v", err)
+	}
+}
+
+// TestTrace tests the output formats of traces.
+func TestTrace(t *testing.T) {
+	m := NewModel()
+	c := m.Int("c", 3)
+	odd := m.Bool("odd")
+	m.AddRule("inc", c.Lt(Int(3)), c.Next().Eq(c.Add(Int(1))).And(odd.Next().Eq(odd.Neg())))
+	m.AddRule("reset", c.Eq(Int(3)), c.Next().Eq(Int(0)).And(odd.Next().Eq(False)))
+
+	init := c.Eq(Int(0)).And(odd.Neg())
+	trace := m.Trace(GenerateExample(m, init, m.EF(c.Eq(Int(2)))))
+	if trace.Len() != 3 || trace.Step(2).Rule != "inc" || trace.Step(2).State.Int("c") != 2 ||
+		!trace.Step(1).State.Bool("odd") {
+		t.Fatalf("unexpected trace %v", trace.Steps())
+	}
+
+	var out strings.Builder
+	trace.WriteCSV(&out)
+	if out.String() != "step,rule,odd,c\n0,,false,0\n1,inc,true,1\n2,inc,false,2\n" {
+		t.Errorf("unexpected CSV:\n%v", out.String())
+	}
+	out.Reset()
+	trace.WriteMarkdown(&out)
+	expected := `| step | rule | odd | c |
+| --- | --- | --- | --- |
+| 0 |  | false | 0 |
+| 1 | inc | true | 1 |
+| 2 | inc | false | 2 |
+`
+	if out.String() != expected {
+		t.Errorf("unexpected Markdown:\n%v", out.String())
+	}
+	out.Reset()
+	trace.WriteJSON(&out)
+	var steps []struct {
+		Step   int
+		Rule   string
+		Values map[string]interface{}
+	}
+	if err := json.Unmarshal([]byte(out.String()), &steps); err != nil || len(steps) != 3 ||
+		steps[1].Rule != "inc" || steps[1].Values["c"] != 1.0 || steps[1].Values["odd"] != true {
+		t.Errorf("unexpected JSON:\n%v", out.String())
+	}
+}
+
+// TestTraceChanges tests that only changed variables are written.
+func TestTraceChanges(t *testing.T) {
+	m := NewModel()
+	a := m.Bool("a")
+	b := m.Bool("b")
+	c := m.Int("c", 3)
+	m.AddRule("flip", True, a.Next().Eq(a.Neg()).And(b.Next().Eq(b)).And(c.Next().Eq(c)))
+	m.AddRule("inc", c.Lt(Int(3)), c.Next().Eq(c.Add(Int(1))).And(a.Next().Eq(a)).And(b.Next().Eq(b)))
+
+	init := a.Neg().And(b.Neg()).And(c.Eq(Int(0)))
+	trace := m.Trace(GenerateExample(m, init, m.EF(a.And(c.Eq(Int(1))))))
+	var out strings.Builder
+	trace.WriteChanges(&out, c.Eq(Int(1)).Next())
+	expected := `-> State 0 <-
+  a = false
+  b = false
+* c = 0
+-> State 1 (flip) <-
+  a = true
+-> State 2 (inc) <-
+* c = 1
+`
+	if trace.Len() != 3 || out.String() != expected {
+		t.Errorf("unexpected output:\n%v", out.String())
+	}
+}
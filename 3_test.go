@@ -63,7 +63,7 @@ func TestNetworkDeadlock(t *testing.T) {
 	t.Skip("This test requires serious optimizations to run")
 
 	for _, instance := range instances {
-		result := findDeadlock(connections, routes, instance.main)
+		result := findDeadlock(t, connections, routes, instance.main)
 		if result != instance.deadlock {
 			t.Errorf("Expected a deadlock in %v steps", instance.deadlock)
 		}
@@ -73,7 +73,7 @@ func TestNetworkDeadlock(t *testing.T) {
 // Given connection channels, routes, and main nodes. Compute how many steps it
 // takes to reach a deadlock starting at an empty network. If no deadlock is
 // reachable the function returns -1.
-func findDeadlock(connections [][]int, routes [][]int, main []int) int {
+func findDeadlock(t *testing.T, connections [][]int, routes [][]int, main []int) int {
 	// Convert connections and routes to 0-based indices.
 	connections = intMatSub(connections, 1)
 	routes = intMatSub(routes, 1)
@@ -166,6 +166,12 @@ func findDeadlock(connections [][]int, routes [][]int, main []int) int {
 	}
 	noDeadlock := emptyNetwork.Or(canReceive).Or(canForward)
 
+	// States without any transition are deadlocks of the network as well (but
+	// not the other way around, since packets can still be sent).
+	if m.Deadlocks().Intersects(noDeadlock) {
+		t.Error("Unexpected deadlock states")
+	}
+
 	// Check if it is possible to end up in a deadlock from an empty network.
 	deadlock := noDeadlock.Neg()
 	sets := m.EF(deadlock)
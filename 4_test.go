@@ -0,0 +1,197 @@
+package ctl
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestSolver tests the SAT solver on random problems and the pigeonhole
+// principle.
+func TestSolver(t *testing.T) {
+	// Compare random 3-SAT problems with brute force.
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 100; n++ {
+		vars, count := 10, 30+r.Intn(20)
+		clauses := make([][]Lit, count)
+		for i := range clauses {
+			for j := 0; j < 3; j++ {
+				l := Lit(1 + r.Intn(vars))
+				if r.Intn(2) == 0 {
+					l = l.Neg()
+				}
+				clauses[i] = append(clauses[i], l)
+			}
+		}
+
+		s := NewSolver()
+		for i := 0; i < vars; i++ {
+			s.NewVar()
+		}
+		for _, c := range clauses {
+			s.AddClause(c...)
+		}
+		// Assume that the first variable is true.
+		sat := s.Solve(1)
+
+		// Check the assignment of the solver.
+		satisfies := func(value func(Lit) bool) bool {
+			for _, c := range clauses {
+				if !value(c[0]) && !value(c[1]) && !value(c[2]) {
+					return false
+				}
+			}
+			return value(1)
+		}
+		if sat && !satisfies(s.Value) {
+			t.Fatal("invalid assignment")
+		}
+
+		// Try all assignments.
+		expected := false
+		for a := 0; a < 1<<vars && !expected; a++ {
+			expected = satisfies(func(l Lit) bool {
+				return ((a>>(l.v()-1))&1 == 1) == (l > 0)
+			})
+		}
+		if sat != expected {
+			t.Fatalf("unexpected result for problem %v", n)
+		}
+	}
+
+	// Four pigeons do not fit in three holes.
+	s := NewSolver()
+	x := make([][]Lit, 4)
+	for p := range x {
+		x[p] = []Lit{s.NewVar(), s.NewVar(), s.NewVar()}
+		s.AddClause(x[p]...)
+	}
+	for h := 0; h < 3; h++ {
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				s.AddClause(x[p][h].Neg(), x[q][h].Neg())
+			}
+		}
+	}
+	if s.Solve() {
+		t.Error("expected pigeonhole problem to be unsatisfiable")
+	}
+
+	// Check failed assumptions.
+	s = NewSolver()
+	a, b, c := s.NewVar(), s.NewVar(), s.NewVar()
+	s.AddClause(a.Neg(), b.Neg())
+	if s.Solve(c, a, b) || len(s.Conflict()) != 2 || !s.Solve(c, a) {
+		t.Error("unexpected conflict")
+	}
+
+	// Temporary clauses with released activation literals do not add variables.
+	for i := 0; i < 100; i++ {
+		act := s.NewVar()
+		s.AddClause(act.Neg(), a.Neg(), c.Neg())
+		if s.Solve(act, a, c) || !s.Solve(a, c) {
+			t.Fatal("unexpected result with activation literal")
+		}
+		s.ReleaseVar(act)
+		s.Simplify()
+	}
+	d := s.NewVar()
+	if s.NumVars() != 4 || !s.Solve(a, c, d) || !s.Solve(a, c, d.Neg()) {
+		t.Error("expected released variables to be reused")
+	}
+}
+
+// TestBMC tests bounded model checking on the marble game.
+func TestBMC(t *testing.T) {
+	m := NewModel()
+	a := m.Int("a", 100)
+	m.Add(a.Leq(Int(95)), a.Next().Eq(a.Add(Int(5))))
+	m.Add(a.Leq(Int(50)), a.Next().Eq(a.Add(a)))
+
+	// A path of the same length is found as with BDDs.
+	init := a.Eq(Int(1))
+	path := m.BMC(init, a.Eq(Int(98)), 10)
+	if len(path) != 7 || path[0].ints["a"] != 1 || path[6].ints["a"] != 98 {
+		t.Error("unexpected path")
+	}
+	for i := 1; i < len(path); i++ {
+		a0, a1 := path[i-1].ints["a"], path[i].ints["a"]
+		if a1 != a0+5 && a1 != 2*a0 {
+			t.Error("unexpected step")
+		}
+	}
+
+	// There is no path within five steps, and there are no values above 100.
+	if len(m.BMC(init, a.Eq(Int(98)), 5)) != 0 ||
+		len(m.BMC(init, Int(100).Lt(a), 20)) != 0 {
+		t.Error("unexpected path")
+	}
+}
+
+// TestKInduction tests k-induction on a property that is not 1-inductive.
+func TestKInduction(t *testing.T) {
+	m := NewModel()
+	x := m.Bool("x")
+	y := m.Bool("y")
+
+	// x := y (y never changes)
+	m.Add(True, x.Next().Eq(y).And(y.Next().Eq(y)))
+
+	// The state !x & y is not reachable, but it violates 1-induction.
+	init := x.Neg().And(y.Neg())
+	if v, _ := m.KInduction(init, x.Neg(), 1); v != Unknown {
+		t.Errorf("expected unknown, got %v", v)
+	}
+	if v, _ := m.KInduction(init, x.Neg(), 2); v != Proved {
+		t.Errorf("expected proved, got %v", v)
+	}
+
+	// Starting from y the property is falsified in one step.
+	v, path := m.KInduction(x.Neg().And(y), x.Neg(), 5)
+	if v != Falsified || len(path) != 2 || !path[1].bools["x"] {
+		t.Errorf("expected falsified in one step, got %v (%v states)", v, len(path))
+	}
+
+	// A counter that wraps around never exceeds its limit (this needs the
+	// domain of the model).
+	m = NewModel()
+	c := m.Int("c", 5)
+	m.Add(True, c.Next().Eq(IntIte(c.Lt(Int(5)), c.Add(Int(1)), Int(0))))
+	if v, _ := m.KInduction(c.Eq(Int(0)), c.Leq(Int(5)), 1); v != Proved {
+		t.Errorf("expected proved, got %v", v)
+	}
+}
+
+// TestIC3 compares IC3 with the reachable states for several properties.
+func TestIC3(t *testing.T) {
+	m := NewModel()
+	a := m.Int("a", 100)
+	m.Add(a.Leq(Int(95)), a.Next().Eq(a.Add(Int(5))))
+	m.Add(a.Leq(Int(50)), a.Next().Eq(a.Add(a)))
+
+	init := a.Eq(Int(1))
+	reachable := m.Reachable(init)
+	for n := uint(0); n <= 20; n++ {
+		bad := a.Eq(Int(n))
+		inv, path := m.IC3(init, bad.Neg())
+		if reachable[len(reachable)-1].Intersects(bad) {
+			// The path should be as short as the path found by BMC.
+			if inv != nil || len(path) != len(m.BMC(init, bad, 20)) ||
+				path[len(path)-1].ints["a"] != int(n) {
+				t.Errorf("expected a path to %v", n)
+			}
+		} else if inv == nil || !inv.Check(init, bad.Neg()) {
+			t.Errorf("expected an invariant for %v", n)
+		}
+	}
+
+	// The state !x & y is not reachable, but it is not excluded by !x alone.
+	m = NewModel()
+	x := m.Bool("x")
+	y := m.Bool("y")
+	m.Add(True, x.Next().Eq(y).And(y.Next().Eq(y)))
+	inv, _ := m.IC3(x.Neg().And(y.Neg()), x.Neg())
+	if inv == nil || !inv.Check(x.Neg().And(y.Neg()), x.Neg()) ||
+		!inv.BDD().Equals(x.Neg().And(y.Neg())) {
+		t.Error("expected invariant !x & !y")
+	}
+}
@@ -0,0 +1,77 @@
+package ctl
+
+import "fmt"
+
+// Array represents a list of integer variables that can be indexed by another
+// integer (which may be a variable itself).
+type Array struct {
+	name  string     // Name of the array
+	elems []*Integer // Integer variable for each element
+}
+
+// Array creates an array of size integer variables that contain the given
+// upperbound.
+func (m *Model) Array(name string, size int, elemUpb uint) *Array {
+	elems := make([]*Integer, size)
+	for k := range elems {
+		elems[k] = m.Int(fmt.Sprintf("%v[%v]", name, k), elemUpb)
+	}
+	return &Array{name, elems}
+}
+
+// Name returns the name of this array.
+func (a *Array) Name() string {
+	return a.name
+}
+
+// Len returns the number of elements in this array.
+func (a *Array) Len() int {
+	return len(a.elems)
+}
+
+// Elem returns the k-th element of this array.
+func (a *Array) Elem(k int) *Integer {
+	return a.elems[k]
+}
+
+// Next returns the array that identifies a in the next step.
+func (a *Array) Next() *Array {
+	elems := make([]*Integer, len(a.elems))
+	for k, elem := range a.elems {
+		elems[k] = elem.Next()
+	}
+	return &Array{fmt.Sprintf("next(%v)", a.name), elems}
+}
+
+// At returns the integer that is equal to the element at idx. If idx is out of
+// range this integer is equal to 0.
+func (a *Array) At(idx *Integer) *Integer {
+	c := NewCase()
+	for k, elem := range a.elems {
+		c.When(idx.Eq(Int(uint(k))), elem)
+	}
+	result := c.Default(Int(0))
+	result.name = fmt.Sprintf("%v[%v]", a.name, idx.Name())
+	return result
+}
+
+// Store returns a BDD that is true when in the next step the element at idx is
+// equal to val and all other elements are unchanged. If idx is out of range all
+// elements are unchanged.
+func (a *Array) Store(idx *Integer, val *Integer) *BDD {
+	store := True
+	for k, elem := range a.elems {
+		value := IntIte(idx.Eq(Int(uint(k))), val, elem)
+		store = store.And(elem.Next().Eq(value))
+	}
+	return store
+}
+
+// Unchanged returns a BDD that is true when no element changes in the next step.
+func (a *Array) Unchanged() *BDD {
+	unchanged := True
+	for _, elem := range a.elems {
+		unchanged = unchanged.And(elem.Next().Eq(elem))
+	}
+	return unchanged
+}
@@ -0,0 +1,137 @@
+package ctl
+
+// Unrolling encodes the transitions of a model for a number of steps in a SAT
+// solver. Each step has its own copy of the state variables, and BDDs are
+// translated to clauses using the Tseitin encoding (one solver variable per BDD
+// node).
+type unrolling struct {
+	m      *Model
+	s      *Solver
+	top    Lit                 // Literal that is always true
+	frames []map[*Variable]Lit // State variables at each step
+	cache  map[unrollKey]Lit   // Encoded BDD nodes
+	steps  int                 // Number of encoded transitions
+}
+
+type unrollKey struct {
+	p    *BDD
+	step int
+}
+
+// Create a new unrolling with a single step (without transitions).
+func newUnrolling(m *Model) *unrolling {
+	s := NewSolver()
+	top := s.NewVar()
+	s.AddClause(top)
+	u := &unrolling{m, s, top, nil, make(map[unrollKey]Lit), 0}
+	u.frame()
+	return u
+}
+
+// Add a new frame of state variables that are in the domain of the model.
+func (u *unrolling) frame() {
+	frame := make(map[*Variable]Lit, len(u.m.vars))
+	for _, v := range u.m.vars {
+		frame[v] = u.s.NewVar()
+	}
+	u.frames = append(u.frames, frame)
+	u.s.AddClause(u.encode(u.m.domain, len(u.frames)-1))
+}
+
+// Add the transitions from the last frame to a new frame.
+func (u *unrolling) step() {
+	u.frame()
+	u.s.AddClause(u.transition(u.steps)...)
+	u.steps++
+}
+
+// Encode each transition of the model from step k to step k+1 (at least one
+// of the returned literals should be true).
+func (u *unrolling) transition(k int) []Lit {
+	lits := make([]Lit, len(u.m.rules))
+	for i, rule := range u.m.rules {
+		lits[i] = u.encode(rule, k)
+	}
+	return lits
+}
+
+// Encode p at step k (next variables refer to step k+1). Returns a literal that
+// is true iff p is true.
+func (u *unrolling) encode(p *BDD, k int) Lit {
+	if !p.Node() {
+		if p.Value {
+			return u.top
+		}
+		return u.top.Neg()
+	}
+	key := unrollKey{p, k}
+	if x, in := u.cache[key]; in {
+		return x
+	}
+
+	v := u.frames[k][p.Var.Norm()]
+	if p.Var.next {
+		v = u.frames[k+1][p.Var.Norm()]
+	}
+	t, f := u.encode(p.True, k), u.encode(p.False, k)
+
+	// x <-> (v ? t : f)
+	x := u.s.NewVar()
+	u.s.AddClause(v.Neg(), t.Neg(), x)
+	u.s.AddClause(v.Neg(), t, x.Neg())
+	u.s.AddClause(v, f.Neg(), x)
+	u.s.AddClause(v, f, x.Neg())
+	u.s.AddClause(t.Neg(), f.Neg(), x)
+	u.s.AddClause(t, f, x.Neg())
+	u.cache[key] = x
+	return x
+}
+
+// Decode the state at step k from the last satisfying assignment.
+func (u *unrolling) state(k int) *State {
+	state := make(map[*Variable]bool, len(u.m.vars))
+	for _, v := range u.m.vars {
+		state[v] = u.s.Value(u.frames[k][v])
+	}
+	return processState(u.m, state, false)
+}
+
+// Decode the path of states at steps 0..k from the last satisfying assignment.
+func (u *unrolling) path(k int) []*State {
+	path := make([]*State, k+1)
+	for i := range path {
+		path[i] = u.state(i)
+	}
+	return path
+}
+
+// BMC searches for a shortest path of at most k steps from a state in init to a
+// state in bad using bounded model checking. Instead of computing the reachable
+// states, the transitions are unrolled into a SAT problem one step at a time.
+// If no path is found an empty path is returned.
+func (m *Model) BMC(init *BDD, bad *BDD, k int) []*State {
+	u := newUnrolling(m)
+	u.s.AddClause(u.encode(init, 0))
+	for i := 0; i <= k; i++ {
+		if u.s.Solve(u.encode(bad, i)) {
+			return u.path(i)
+		}
+		if i < k {
+			u.step()
+		}
+	}
+	return []*State{}
+}
+
+// Require that the states at steps i and j are different.
+func (u *unrolling) distinct(i int, j int) {
+	diff := make([]Lit, 0, len(u.m.vars))
+	for _, v := range u.m.vars {
+		// d -> (x_i xor x_j)
+		a, b, d := u.frames[i][v], u.frames[j][v], u.s.NewVar()
+		u.s.AddClause(d.Neg(), a, b)
+		u.s.AddClause(d.Neg(), a.Neg(), b.Neg())
+		diff = append(diff, d)
+	}
+	u.s.AddClause(diff...)
+}
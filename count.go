@@ -0,0 +1,67 @@
+package ctl
+
+import "fmt"
+
+// AtLeastK returns a BDD that is true when at least k of the given BDDs are.
+func AtLeastK(bools []*BDD, k int) *BDD {
+	if k <= 0 {
+		return True
+	} else if k > len(bools) {
+		return False
+	}
+	return counts(bools, k)[k]
+}
+
+// AtMostK returns a BDD that is true when at most k of the given BDDs are.
+func AtMostK(bools []*BDD, k int) *BDD {
+	return AtLeastK(bools, k+1).Neg()
+}
+
+// ExactlyK returns a BDD that is true when exactly k of the given BDDs are.
+func ExactlyK(bools []*BDD, k int) *BDD {
+	if k < 0 || k > len(bools) {
+		return False
+	}
+	return counts(bools, k+1)[k]
+}
+
+// Count returns the integer that is equal to the number of given BDDs that are
+// true.
+func Count(bools []*BDD) *Integer {
+	exact := counts(bools, len(bools)+1)
+	bits := make([]*BDD, bitcount(uint(len(bools))))
+	for n := range bits {
+		bits[n] = False
+		for k := 0; k <= len(bools); k++ {
+			if (k>>n)&1 == 1 {
+				bits[n] = bits[n].Or(exact[k])
+			}
+		}
+	}
+	name := fmt.Sprintf("count(%v)", len(bools))
+	return compute(name, bits, false)
+}
+
+// Compute BDDs that are true when exactly k of the given BDDs are true for each
+// k < limit (using dynamic programming over the BDDs). The BDD at index limit
+// is true when at least limit of the given BDDs are true.
+func counts(bools []*BDD, limit int) []*BDD {
+	result := make([]*BDD, limit+1)
+	result[0] = True
+	for k := 1; k <= limit; k++ {
+		result[k] = False
+	}
+	for _, b := range bools {
+		// Update from high to low so that result[k-1] is not yet updated.
+		if limit > 0 {
+			result[limit] = result[limit].Or(result[limit-1].And(b))
+		}
+		for k := limit - 1; k > 0; k-- {
+			result[k] = result[k].And(b.Neg()).Or(result[k-1].And(b))
+		}
+		if limit > 0 {
+			result[0] = result[0].And(b.Neg())
+		}
+	}
+	return result
+}
@@ -0,0 +1,19 @@
+package ctl
+
+// Deadlocks returns all states (in the domain of the model) that do not have a
+// successor.
+func (m *Model) Deadlocks() *BDD {
+	return m.domain.And(m.EX(True, True).Neg())
+}
+
+// ReachableDeadlocks returns all deadlock states that are reachable from init.
+func (m *Model) ReachableDeadlocks(init *BDD) *BDD {
+	reachable := m.Reachable(init)
+	return reachable[len(reachable)-1].And(m.Deadlocks())
+}
+
+// DeadlockExample generates a shortest path from an accepted initial state to
+// a deadlock state. If no deadlock is reachable an empty path is returned.
+func DeadlockExample(m *Model, init *BDD) []*State {
+	return GenerateTrace(m, m.Reachable(init), m.Deadlocks())
+}
@@ -0,0 +1,129 @@
+package ctl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DotOptions configures the output of WriteDot.
+type DotOptions struct {
+	Name   string     // Name of the graph
+	Groups []*Integer // Integers whose bits (and next bits) are drawn in a box
+}
+
+// DotRoot is a labelled BDD in a diagram.
+type DotRoot struct {
+	Label string
+	BDD   *BDD
+}
+
+// WriteDot writes p as a Graphviz DOT graph. Nodes are labelled with the name
+// of their variable, the high branch is drawn solid and the low branch dashed.
+func WriteDot(w io.Writer, p *BDD, opts DotOptions) error {
+	return WriteDotRoots(w, []DotRoot{{"", p}}, opts)
+}
+
+// WriteDotRoots writes several BDDs into one Graphviz DOT graph in which shared
+// nodes are drawn once (for example the transitions returned by Model.Rules).
+// Each root with a label is pointed to by a box with that label.
+func WriteDotRoots(w io.Writer, roots []DotRoot, opts DotOptions) error {
+	// Number all nodes in depth-first order.
+	ids := make(map[*BDD]int)
+	nodes := make([]*BDD, 0)
+	var visit func(p *BDD)
+	visit = func(p *BDD) {
+		if _, in := ids[p]; in {
+			return
+		}
+		ids[p] = len(nodes)
+		nodes = append(nodes, p)
+		if p.Node() {
+			visit(p.True)
+			visit(p.False)
+		}
+	}
+	for _, root := range roots {
+		visit(root.BDD)
+	}
+	name := func(p *BDD) string {
+		if !p.Node() {
+			if p.Value {
+				return "T"
+			}
+			return "F"
+		}
+		return fmt.Sprintf("n%v", ids[p])
+	}
+
+	var b strings.Builder
+	graph := opts.Name
+	if graph == "" {
+		graph = "bdd"
+	}
+	fmt.Fprintf(&b, "digraph %q {\n", graph)
+	for i, root := range roots {
+		if root.Label != "" {
+			fmt.Fprintf(&b, "  r%v [label=%q, shape=box];\n", i, root.Label)
+			fmt.Fprintf(&b, "  r%v -> %v;\n", i, name(root.BDD))
+		}
+	}
+
+	// Nodes with the same variable are drawn at the same height, and the nodes
+	// of the bits of an integer are grouped.
+	levels := make(map[*Variable][]*BDD)
+	vars := make([]*Variable, 0)
+	for _, p := range nodes {
+		if !p.Node() {
+			label := "0"
+			if p.Value {
+				label = "1"
+			}
+			fmt.Fprintf(&b, "  %v [label=%q, shape=box];\n", name(p), label)
+			continue
+		}
+		if len(levels[p.Var]) == 0 {
+			vars = append(vars, p.Var)
+		}
+		levels[p.Var] = append(levels[p.Var], p)
+	}
+	// Lt is not used, since it adds variables that are not yet ordered to the
+	// ordering (such variables are kept in the order in which they are found).
+	sort.SliceStable(vars, func(i, j int) bool { return vars[i].seq < vars[j].seq })
+	writeLevel := func(indent string, v *Variable) {
+		fmt.Fprintf(&b, "%v{ rank=same;", indent)
+		for _, p := range levels[v] {
+			fmt.Fprintf(&b, " %v [label=%q];", name(p), v.Name)
+		}
+		b.WriteString(" }\n")
+	}
+	grouped := make(map[*Variable]bool)
+	for _, i := range opts.Groups {
+		fmt.Fprintf(&b, "  subgraph %q {\n    label=%q;\n", "cluster_"+i.Name(), i.Name())
+		for _, v := range vars {
+			for _, bit := range i.vars {
+				if v.Norm() == bit {
+					writeLevel("    ", v)
+					grouped[v] = true
+				}
+			}
+		}
+		b.WriteString("  }\n")
+	}
+	for _, v := range vars {
+		if !grouped[v] {
+			writeLevel("  ", v)
+		}
+	}
+
+	for _, p := range nodes {
+		if p.Node() {
+			fmt.Fprintf(&b, "  %v -> %v;\n", name(p), name(p.True))
+			fmt.Fprintf(&b, "  %v -> %v [style=dashed];\n", name(p), name(p.False))
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
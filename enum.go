@@ -0,0 +1,140 @@
+package ctl
+
+import "fmt"
+
+// Encoding determines how the values of an enum are stored in variables.
+type Encoding int
+
+const (
+	// Binary stores the index of the value using log2(n) variables.
+	Binary Encoding = iota
+	// OneHot uses one variable per value of which exactly one is true.
+	OneHot
+)
+
+// Enum represents a variable that takes one of a list of symbolic values.
+type Enum struct {
+	name     string      // Name of the enum
+	values   []string    // Symbolic values
+	vars     []*Variable // Variables that encode the value
+	encoding Encoding    // Encoding of the value
+}
+
+// Enum creates a new enum variable with a binary encoding.
+func (m *Model) Enum(name string, values ...string) *Enum {
+	return m.EnumEncoded(name, Binary, values...)
+}
+
+// EnumEncoded creates a new enum variable with the given encoding.
+func (m *Model) EnumEncoded(name string, encoding Encoding, values ...string) *Enum {
+	if len(values) == 0 {
+		panic("enum without values")
+	}
+	var vars []*Variable
+	if encoding == OneHot {
+		vars = make([]*Variable, len(values))
+		for i, value := range values {
+			vars[i] = m.Var(fmt.Sprintf("%v@%v", name, value), false)
+		}
+	} else {
+		vars = make([]*Variable, bitcount(uint(len(values)-1)))
+		for i := range vars {
+			vars[i] = m.Var(fmt.Sprintf("%v@%v", name, i), false)
+		}
+	}
+	e := &Enum{name, values, vars, encoding}
+	m.enums = append(m.enums, e)
+	m.restrict(e.Domain())
+	return e
+}
+
+// Name returns the name of this enum.
+func (e *Enum) Name() string {
+	return e.name
+}
+
+// Values returns the symbolic values of this enum.
+func (e *Enum) Values() []string {
+	return e.values
+}
+
+// Next returns the enum that identifies e in the next step.
+func (e *Enum) Next() *Enum {
+	vars := make([]*Variable, len(e.vars))
+	for i, v := range e.vars {
+		vars[i] = v.Next()
+	}
+	return &Enum{fmt.Sprintf("next(%v)", e.name), e.values, vars, e.encoding}
+}
+
+// Is returns a BDD that is true when e has the given value.
+func (e *Enum) Is(value string) *BDD {
+	for i, v := range e.values {
+		if v == value {
+			return e.index(i)
+		}
+	}
+	panic(fmt.Sprintf("%v is not a value of %v", value, e.name))
+}
+
+// Eq returns a BDD that is true when e and f have the same value.
+func (e *Enum) Eq(f *Enum) *BDD {
+	eq := False
+	for _, value := range e.values {
+		for _, other := range f.values {
+			if value == other {
+				eq = eq.Or(e.Is(value).And(f.Is(value)))
+			}
+		}
+	}
+	return eq
+}
+
+// Domain returns a BDD that is true when e encodes one of its values.
+func (e *Enum) Domain() *BDD {
+	domain := False
+	for i := range e.values {
+		domain = domain.Or(e.index(i))
+	}
+	return domain
+}
+
+// BDD that is true when e encodes the i-th value.
+func (e *Enum) index(i int) *BDD {
+	p := True
+	for n, v := range e.vars {
+		bit := n == i
+		if e.encoding == Binary {
+			bit = (i>>n)&1 == 1
+		}
+		if bit {
+			p = p.And(Node(v, True, False))
+		} else {
+			p = p.And(Node(v, False, True))
+		}
+	}
+	return p
+}
+
+// Decode the value of e in the given state (variables are removed from the
+// state).
+func (e *Enum) decode(state map[*Variable]bool) string {
+	index := 0
+	if e.encoding == OneHot {
+		index = -1
+	}
+	for n, v := range e.vars {
+		if state[v] {
+			if e.encoding == OneHot {
+				index = n
+			} else {
+				index += 1 << n
+			}
+		}
+		delete(state, v)
+	}
+	if index < 0 || index >= len(e.values) {
+		return "?"
+	}
+	return e.values[index]
+}
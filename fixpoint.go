@@ -0,0 +1,203 @@
+package ctl
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Progress describes an iteration of a fixpoint computation.
+type Progress struct {
+	Iteration int // Number of the iteration (starting at 1)
+	Frontier  int // Number of nodes in the states that changed
+	Nodes     int // Number of nodes in the new set of states
+}
+
+// SetProgress sets a function that is called after each iteration of a
+// fixpoint computation (EU, EG, Reachable, AU, AR, ER and their variants). A
+// nil function disables progress reporting.
+func (m *Model) SetProgress(progress func(Progress)) {
+	m.progress = progress
+}
+
+// Report the progress of an iteration.
+func (m *Model) report(iteration int, frontier *BDD, states *BDD) {
+	if m.progress != nil {
+		m.progress(Progress{iteration, frontier.Size(), states.Size()})
+	}
+}
+
+// Key of the label of a context (see WithLabel).
+type labelKey struct{}
+
+// WithLabel returns a context that labels the fixpoint computations that are
+// run with it (for example with the CTL formula that is checked). The label is
+// shown in the statistics of the model (see Stats).
+func WithLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, labelKey{}, label)
+}
+
+// Compute the sets that are obtained by repeatedly applying next to first until
+// the set no longer changes. If ctx is cancelled (or the memory limit is
+// exceeded), the sets computed so far are returned with the error. The time of
+// each iteration is recorded under the name of the operator and the label of
+// the context (or a description of the operands).
+func (m *Model) fixpoint(ctx context.Context, operator string, operands []*BDD, first *BDD, next func(last *BDD) *BDD) ([]*BDD, error) {
+	result := make([]*BDD, 0)
+	stats := m.record(ctx, operator, operands)
+	err := Run(ctx, func() {
+		for last := first; ; {
+			interrupted(ctx)
+			result = append(result, last)
+			start := time.Now()
+			n := iterate(func() *BDD { return next(last) })
+			stats.Iterations = append(stats.Iterations, time.Since(start))
+			if n.Equals(last) {
+				return
+			}
+			m.report(len(result), n.Xor(last), n)
+			last = n
+		}
+	})
+	return result, err
+}
+
+// ReachableContext is like Reachable, but stops when ctx is cancelled or the
+// memory limit is exceeded. The sets computed so far are returned with the
+// error.
+func (m *Model) ReachableContext(ctx context.Context, init *BDD) ([]*BDD, error) {
+	result := make([]*BDD, 0)
+	stats := m.record(ctx, "Reachable", []*BDD{init})
+	err := Run(ctx, func() {
+		last := init.And(m.domain)
+		for frontier := last; ; {
+			interrupted(ctx)
+			result = append(result, last)
+			// Only compute successors of newly reached states.
+			start := time.Now()
+			frontier = iterate(func() *BDD { return m.EXInv(frontier, True).And(last.Neg()) })
+			stats.Iterations = append(stats.Iterations, time.Since(start))
+			if frontier == False {
+				return
+			}
+			last = last.Or(frontier)
+			m.report(len(result), frontier, last)
+		}
+	})
+	return result, err
+}
+
+// Stop a fixpoint computation if ctx is cancelled (the error is returned by
+// Run, so a computation that is complete is not affected).
+func interrupted(ctx context.Context) {
+	if err := ctx.Err(); err != nil {
+		panic(interrupt{err})
+	}
+}
+
+// Compute an iteration of a fixpoint computation. If the memory limit is
+// exceeded, the variables are reordered and the iteration is tried again.
+func iterate(f func() *BDD) *BDD {
+	var result *BDD
+	for retried := false; ; retried = true {
+		err := Run(context.Background(), func() { result = f() })
+		if err == nil {
+			return result
+		} else if retried || !errors.Is(err, ErrMemoryLimit) {
+			panic(interrupt{err})
+		}
+		Reorder()
+	}
+}
+
+// Get the result of a fixpoint computation that cannot be cancelled (so it can
+// only fail if the memory limit is exceeded, see SetMemoryLimit).
+func must(result []*BDD, err error) []*BDD {
+	if err != nil {
+		abort(err)
+	}
+	return result
+}
+
+// EGContext is like EG, but stops when ctx is cancelled.
+func (m *Model) EGContext(ctx context.Context, condition *BDD) (_ []*BDD, err error) {
+	defer recoverLimit(&err)
+	states := condition.And(m.domain)
+	return m.fixpoint(ctx, "EG", []*BDD{condition}, states, func(last *BDD) *BDD {
+		return last.And(m.EX(states, last))
+	})
+}
+
+// EUContext is like EU, but stops when ctx is cancelled.
+func (m *Model) EUContext(ctx context.Context, step *BDD, goal *BDD) ([]*BDD, error) {
+	return m.eu(ctx, "EU", []*BDD{step, goal}, step, goal)
+}
+
+// EFContext is like EF, but stops when ctx is cancelled.
+func (m *Model) EFContext(ctx context.Context, goal *BDD) ([]*BDD, error) {
+	return m.eu(ctx, "EF", []*BDD{goal}, True, goal)
+}
+
+func (m *Model) eu(ctx context.Context, operator string, operands []*BDD, step *BDD, goal *BDD) (_ []*BDD, err error) {
+	defer recoverLimit(&err)
+	return m.fixpoint(ctx, operator, operands, goal.And(m.domain), func(last *BDD) *BDD {
+		return last.Or(m.EX(step, last))
+	})
+}
+
+// AUContext is like AU, but stops when ctx is cancelled.
+func (m *Model) AUContext(ctx context.Context, step *BDD, goal *BDD) ([]*BDD, error) {
+	return m.au(ctx, "AU", []*BDD{step, goal}, step, goal)
+}
+
+// AFContext is like AF, but stops when ctx is cancelled.
+func (m *Model) AFContext(ctx context.Context, goal *BDD) ([]*BDD, error) {
+	return m.au(ctx, "AF", []*BDD{goal}, True, goal)
+}
+
+func (m *Model) au(ctx context.Context, operator string, operands []*BDD, step *BDD, goal *BDD) (_ []*BDD, err error) {
+	defer recoverLimit(&err)
+	dead, err := m.checkDeadlocks(step.And(goal.Neg()))
+	if err != nil {
+		return nil, err
+	}
+
+	// A deadlock state that is not in goal does not reach goal.
+	step = step.And(dead.Neg())
+	return m.fixpoint(ctx, operator, operands, goal.And(m.domain), func(last *BDD) *BDD {
+		return last.Or(m.ax(dead, step, last))
+	})
+}
+
+// AGContext is like AG, but stops when ctx is cancelled.
+func (m *Model) AGContext(ctx context.Context, condition *BDD) ([]*BDD, error) {
+	return m.ar(ctx, "AG", []*BDD{condition}, False, condition)
+}
+
+// ARContext is like AR, but stops when ctx is cancelled.
+func (m *Model) ARContext(ctx context.Context, release *BDD, condition *BDD) ([]*BDD, error) {
+	return m.ar(ctx, "AR", []*BDD{release, condition}, release, condition)
+}
+
+func (m *Model) ar(ctx context.Context, operator string, operands []*BDD, release *BDD, condition *BDD) (_ []*BDD, err error) {
+	defer recoverLimit(&err)
+	dead, err := m.checkDeadlocks(condition.And(release.Neg()))
+	if err != nil {
+		return nil, err
+	}
+	return m.fixpoint(ctx, operator, operands, condition.And(m.domain), func(last *BDD) *BDD {
+		return last.And(release.Or(m.ax(dead, True, last)))
+	})
+}
+
+// ERContext is like ER, but stops when ctx is cancelled.
+func (m *Model) ERContext(ctx context.Context, release *BDD, condition *BDD) (_ []*BDD, err error) {
+	defer recoverLimit(&err)
+	dead, err := m.checkDeadlocks(condition.And(release.Neg()))
+	if err != nil {
+		return nil, err
+	}
+	return m.fixpoint(ctx, "ER", []*BDD{release, condition}, condition.And(m.domain), func(last *BDD) *BDD {
+		return last.And(release.Or(dead).Or(m.EX(True, last)))
+	})
+}
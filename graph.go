@@ -0,0 +1,216 @@
+package ctl
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// GraphFormat is a file format for state graphs.
+type GraphFormat int
+
+const (
+	// DOT is the Graphviz format.
+	DOT GraphFormat = iota
+	// GraphML is an XML format that is supported by most graph editors.
+	GraphML
+	// JSON lists the states and edges as JSON objects.
+	JSON
+)
+
+// Highlight is a named set of states that is marked in a state graph.
+type Highlight struct {
+	Name   string
+	States *BDD
+}
+
+// A state in a state graph.
+type graphState struct {
+	state      *State
+	init       bool
+	highlights []int // Indices of the highlights that contain this state
+}
+
+// An edge in a state graph (labelled with the rule that fired).
+type graphEdge struct {
+	from, to int
+	rule     string
+}
+
+// ExportGraph writes the states that are reachable from init and the
+// transitions between them in the given format. Each transition is labelled
+// with the name of the rule that fired (see AddRule), and states in each of the
+// highlights are marked (for example the deadlock states). Since all states
+// are enumerated, this is only feasible for small models.
+func (m *Model) ExportGraph(init *BDD, w io.Writer, format GraphFormat, highlights ...Highlight) (err error) {
+	defer recoverLimit(&err)
+	reachable, err := m.ReachableContext(context.Background(), init)
+	if err != nil {
+		return err
+	}
+	all := reachable[len(reachable)-1]
+
+	// Enumerate and sort all states (including auxiliary variables).
+	type node struct {
+		cube  *BDD
+		state *State
+	}
+	nodes := make([]node, 0)
+	for _, assignment := range expandStates(m.vars, true, unpackBDD(all)) {
+		cube := assignmentBDD(assignment)
+		nodes = append(nodes, node{cube, processState(m, assignment, false)})
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].state.Less(nodes[j].state)
+	})
+	index := make(map[*BDD]int, len(nodes))
+	states := make([]graphState, len(nodes))
+	for i, n := range nodes {
+		index[n.cube] = i
+		states[i] = graphState{n.state, n.cube.Intersects(init), nil}
+		for j, h := range highlights {
+			if n.cube.Intersects(h.States) {
+				states[i].highlights = append(states[i].highlights, j)
+			}
+		}
+	}
+
+	// Find the successors of each state for each rule.
+	edges := make([]graphEdge, 0)
+	for i, n := range nodes {
+		for r, rule := range m.rules {
+			next := n.cube.And(rule)
+			for _, v := range m.vars {
+				next = next.Exists(v)
+			}
+			for _, assignment := range expandStates(m.vars, true, unpackBDD(next.Norm())) {
+				edges = append(edges, graphEdge{i, index[assignmentBDD(assignment)], m.names[r]})
+			}
+		}
+	}
+
+	switch format {
+	case DOT:
+		return writeGraphDot(w, states, edges, highlights)
+	case GraphML:
+		return writeGraphML(w, states, edges, highlights)
+	case JSON:
+		return writeGraphJSON(w, states, edges, highlights)
+	}
+	return fmt.Errorf("unknown graph format %v", format)
+}
+
+// Get the BDD that is true only for the given assignment.
+func assignmentBDD(assignment map[*Variable]bool) *BDD {
+	p := True
+	for v, value := range assignment {
+		if value {
+			p = p.And(Node(v, True, False))
+		} else {
+			p = p.And(Node(v, False, True))
+		}
+	}
+	return p
+}
+
+// Get the names of the highlights that contain a state.
+func (s graphState) names(highlights []Highlight) []string {
+	names := make([]string, len(s.highlights))
+	for i, j := range s.highlights {
+		names[i] = highlights[j].Name
+	}
+	return names
+}
+
+// Colors for highlighted states in DOT graphs (by the first highlight).
+var highlightColors = []string{"lightcoral", "lightblue", "palegreen", "khaki", "plum"}
+
+func writeGraphDot(w io.Writer, states []graphState, edges []graphEdge, highlights []Highlight) error {
+	var b strings.Builder
+	b.WriteString("digraph \"states\" {\n")
+	for i, s := range states {
+		attrs := fmt.Sprintf("label=%q", strings.ReplaceAll(s.state.String(), ", ", "\n"))
+		if s.init {
+			attrs += ", peripheries=2"
+		}
+		if len(s.highlights) > 0 {
+			attrs += fmt.Sprintf(", style=filled, fillcolor=%v, tooltip=%q",
+				highlightColors[s.highlights[0]%len(highlightColors)],
+				strings.Join(s.names(highlights), ", "))
+		}
+		fmt.Fprintf(&b, "  s%v [%v];\n", i, attrs)
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&b, "  s%v -> s%v [label=%q];\n", e.from, e.to, e.rule)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeGraphML(w io.Writer, states []graphState, edges []graphEdge, highlights []Highlight) error {
+	var b strings.Builder
+	escape := func(s string) string {
+		var e strings.Builder
+		xml.EscapeText(&e, []byte(s))
+		return e.String()
+	}
+	b.WriteString(xml.Header)
+	b.WriteString("<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\">\n")
+	b.WriteString("  <key id=\"label\" for=\"node\" attr.name=\"label\" attr.type=\"string\"/>\n")
+	b.WriteString("  <key id=\"init\" for=\"node\" attr.name=\"init\" attr.type=\"boolean\"/>\n")
+	for i, h := range highlights {
+		fmt.Fprintf(&b, "  <key id=\"h%v\" for=\"node\" attr.name=\"%v\" attr.type=\"boolean\">\n", i, escape(h.Name))
+		b.WriteString("    <default>false</default>\n  </key>\n")
+	}
+	b.WriteString("  <key id=\"rule\" for=\"edge\" attr.name=\"rule\" attr.type=\"string\"/>\n")
+	b.WriteString("  <graph id=\"states\" edgedefault=\"directed\">\n")
+	for i, s := range states {
+		fmt.Fprintf(&b, "    <node id=\"s%v\">\n", i)
+		fmt.Fprintf(&b, "      <data key=\"label\">%v</data>\n", escape(s.state.String()))
+		fmt.Fprintf(&b, "      <data key=\"init\">%v</data>\n", s.init)
+		for _, j := range s.highlights {
+			fmt.Fprintf(&b, "      <data key=\"h%v\">true</data>\n", j)
+		}
+		b.WriteString("    </node>\n")
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&b, "    <edge source=\"s%v\" target=\"s%v\">\n", e.from, e.to)
+		fmt.Fprintf(&b, "      <data key=\"rule\">%v</data>\n", escape(e.rule))
+		b.WriteString("    </edge>\n")
+	}
+	b.WriteString("  </graph>\n</graphml>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeGraphJSON(w io.Writer, states []graphState, edges []graphEdge, highlights []Highlight) error {
+	type jsonState struct {
+		ID         int                    `json:"id"`
+		Init       bool                   `json:"init"`
+		Values     map[string]interface{} `json:"values"`
+		Highlights []string               `json:"highlights"`
+	}
+	type jsonEdge struct {
+		From int    `json:"from"`
+		To   int    `json:"to"`
+		Rule string `json:"rule"`
+	}
+	graph := struct {
+		States []jsonState `json:"states"`
+		Edges  []jsonEdge  `json:"edges"`
+	}{make([]jsonState, len(states)), make([]jsonEdge, len(edges))}
+	for i, s := range states {
+		graph.States[i] = jsonState{i, s.init, s.state.values(), s.names(highlights)}
+	}
+	for i, e := range edges {
+		graph.Edges[i] = jsonEdge{e.from, e.to, e.rule}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(graph)
+}
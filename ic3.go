@@ -0,0 +1,304 @@
+package ctl
+
+// Invariant is an inductive invariant that is found by IC3. It consists of the
+// domain of the model and a set of clauses over the state variables.
+type Invariant struct {
+	m       *Model
+	Clauses [][]*BDD // Each literal is a variable or its negation
+}
+
+// BDD returns the states that satisfy the invariant.
+func (inv *Invariant) BDD() *BDD {
+	result := inv.m.domain
+	for _, clause := range inv.Clauses {
+		c := False
+		for _, l := range clause {
+			c = c.Or(l)
+		}
+		result = result.And(c)
+	}
+	return result
+}
+
+// Check verifies (without the SAT solver) that the invariant contains init, is
+// closed under the transitions of the model, and implies p.
+func (inv *Invariant) Check(init *BDD, p *BDD) bool {
+	states := inv.BDD()
+	return init.And(inv.m.domain).And(states.Neg()) == False &&
+		inv.m.EX(states, states.Neg()) == False &&
+		states.And(p.Neg()) == False
+}
+
+// A cube is a conjunction of state variable literals.
+type cube []cubeLit
+
+type cubeLit struct {
+	v     *Variable
+	value bool
+}
+
+func (l cubeLit) bdd() *BDD {
+	if l.value {
+		return Node(l.v, True, False)
+	}
+	return Node(l.v, False, True)
+}
+
+func (c cube) bdd() *BDD {
+	result := True
+	for _, l := range c {
+		result = result.And(l.bdd())
+	}
+	return result
+}
+
+// A proof obligation: the states in a cube must be blocked at some level to
+// prevent the next obligation (which leads to a bad state).
+type obligation struct {
+	c     cube
+	level int
+	next  *obligation
+}
+
+// State of the IC3 algorithm. Frame i consists of the lemmas in levels[i:],
+// which are activated in the solver using acts[i]. Frame 0 is init.
+type pdr struct {
+	u       *unrolling
+	init    *BDD
+	bad     Lit
+	trans   Lit // Activates the transitions (deadlock states can be bad)
+	acts    []Lit
+	levels  [][]cube
+	retired int // Activation literals released since the last simplification
+}
+
+// IC3 proves that p holds in all states that are reachable from init (AG p)
+// using the IC3/PDR algorithm. Instead of computing the reachable states, an
+// inductive invariant that implies p is constructed from clauses that block
+// states from which p can be violated. If p holds the invariant is returned,
+// otherwise a shortest path to a state that violates p is returned.
+func (m *Model) IC3(init *BDD, p *BDD) (*Invariant, []*State) {
+	u := newUnrolling(m)
+	u.frame()
+	trans := u.s.NewVar()
+	u.s.AddClause(append([]Lit{trans.Neg()}, u.transition(0)...)...)
+	r := &pdr{u, init, u.encode(p, 0).Neg(), trans, nil, nil, 0}
+	r.frame()
+	u.s.AddClause(r.acts[0].Neg(), u.encode(init, 0))
+	if u.s.Solve(r.acts[0], r.bad) {
+		return nil, []*State{u.state(0)}
+	}
+
+	r.frame()
+	for k := 1; ; k++ {
+		// Block all bad states in the last frame.
+		for u.s.Solve(append(r.assumptions(k), r.bad)...) {
+			if path := r.block(&obligation{r.model(), k, nil}, k); path != nil {
+				return nil, path
+			}
+		}
+
+		// Propagate lemmas to the next frame until two frames are equal.
+		r.frame()
+		for i := 1; i <= k; i++ {
+			lemmas := r.levels[i]
+			r.levels[i] = nil
+			for _, c := range lemmas {
+				if ok, _ := r.consecution(c, i); ok {
+					r.lemma(c, i+1)
+				} else {
+					r.levels[i] = append(r.levels[i], c)
+				}
+			}
+			if len(r.levels[i]) == 0 {
+				return r.invariant(i + 1), nil
+			}
+		}
+	}
+}
+
+// Add a new frame.
+func (r *pdr) frame() {
+	r.acts = append(r.acts, r.u.s.NewVar())
+	r.levels = append(r.levels, nil)
+}
+
+// Get the assumptions that activate frame i.
+func (r *pdr) assumptions(i int) []Lit {
+	if i == 0 {
+		return []Lit{r.acts[0]}
+	}
+	return append([]Lit{}, r.acts[i:]...)
+}
+
+// Get the literals of a cube at step k.
+func (r *pdr) lits(c cube, k int) []Lit {
+	lits := make([]Lit, len(c))
+	for i, l := range c {
+		lits[i] = r.u.frames[k][l.v]
+		if !l.value {
+			lits[i] = lits[i].Neg()
+		}
+	}
+	return lits
+}
+
+// Get the current state of the last satisfying assignment as a cube.
+func (r *pdr) model() cube {
+	c := make(cube, len(r.u.m.vars))
+	for i, v := range r.u.m.vars {
+		c[i] = cubeLit{v, r.u.s.Value(r.u.frames[0][v])}
+	}
+	return c
+}
+
+// Block the states in c at the given level and all frames before it.
+func (r *pdr) lemma(c cube, level int) {
+	r.levels[level] = append(r.levels[level], c)
+	clause := []Lit{r.acts[level].Neg()}
+	for _, l := range r.lits(c, 0) {
+		clause = append(clause, l.Neg())
+	}
+	r.u.s.AddClause(clause...)
+}
+
+// Check if c is unreachable in one step from frame i outside of c. If so, the
+// subset of c that is used in the proof is returned. Otherwise a predecessor is
+// available using model.
+func (r *pdr) consecution(c cube, i int) (bool, cube) {
+	// Add !c using a temporary activation literal.
+	act := r.u.s.NewVar()
+	clause := []Lit{act.Neg()}
+	for _, l := range r.lits(c, 0) {
+		clause = append(clause, l.Neg())
+	}
+	r.u.s.AddClause(clause...)
+	defer r.retire(act)
+
+	next := r.lits(c, 1)
+	if r.u.s.Solve(append(append(r.assumptions(i), act, r.trans), next...)...) {
+		return false, nil
+	}
+	failed := make(map[Lit]bool)
+	for _, l := range r.u.s.Conflict() {
+		failed[l] = true
+	}
+	core := make(cube, 0)
+	for j, l := range c {
+		if failed[next[j]] {
+			core = append(core, l)
+		}
+	}
+	return true, core
+}
+
+// Remove the temporary clause of a consecution query, and reuse its
+// activation literal once enough of them have been released.
+func (r *pdr) retire(act Lit) {
+	r.u.s.ReleaseVar(act)
+	if r.retired++; r.retired == 100 {
+		r.u.s.Simplify()
+		r.retired = 0
+	}
+}
+
+// Generalize a cube that is blocked relative to frame i by removing literals
+// while it remains blocked and does not contain initial states.
+func (r *pdr) generalize(c cube, core cube, i int) cube {
+	// Add literals of c until the core no longer intersects init.
+	for j := 0; r.init.Intersects(core.bdd()); j++ {
+		if !r.contains(core, c[j]) {
+			core = append(core, c[j])
+		}
+	}
+	for j := 0; j < len(core); j++ {
+		d := append(append(cube{}, core[:j]...), core[j+1:]...)
+		if r.init.Intersects(d.bdd()) {
+			continue
+		}
+		if ok, smaller := r.consecution(d, i); ok {
+			core = d
+			if !r.init.Intersects(smaller.bdd()) {
+				core = smaller
+			}
+			j = -1
+		}
+	}
+	return core
+}
+
+func (r *pdr) contains(c cube, l cubeLit) bool {
+	for _, k := range c {
+		if k == l {
+			return true
+		}
+	}
+	return false
+}
+
+// Block an obligation in frame k. If it cannot be blocked a path from init to
+// a bad state is returned.
+func (r *pdr) block(start *obligation, k int) []*State {
+	queue := []*obligation{start}
+	for len(queue) > 0 {
+		// Handle the obligation at the lowest level first.
+		j := 0
+		for i := range queue {
+			if queue[i].level < queue[j].level {
+				j = i
+			}
+		}
+		ob := queue[j]
+
+		ok, core := r.consecution(ob.c, ob.level-1)
+		if !ok {
+			pred := &obligation{r.model(), ob.level - 1, ob}
+			if pred.level == 0 || r.init.Intersects(pred.c.bdd()) {
+				return r.path(pred)
+			}
+			queue = append(queue, pred)
+			continue
+		}
+		queue = append(queue[:j], queue[j+1:]...)
+
+		// Block a generalization at the highest possible level.
+		g := r.generalize(ob.c, core, ob.level-1)
+		level := ob.level
+		for level < k {
+			if ok, _ := r.consecution(g, level); !ok {
+				break
+			}
+			level++
+		}
+		r.lemma(g, level)
+	}
+	return nil
+}
+
+// Decode the path of states from an obligation to the bad state.
+func (r *pdr) path(ob *obligation) []*State {
+	path := make([]*State, 0)
+	for ; ob != nil; ob = ob.next {
+		state := make(map[*Variable]bool, len(ob.c))
+		for _, l := range ob.c {
+			state[l.v] = l.value
+		}
+		path = append(path, processState(r.u.m, state, false))
+	}
+	return path
+}
+
+// Get the invariant that consists of frame i.
+func (r *pdr) invariant(i int) *Invariant {
+	inv := &Invariant{r.u.m, make([][]*BDD, 0)}
+	for _, lemmas := range r.levels[i:] {
+		for _, c := range lemmas {
+			clause := make([]*BDD, len(c))
+			for j, l := range c {
+				clause[j] = l.bdd().Neg()
+			}
+			inv.Clauses = append(inv.Clauses, clause)
+		}
+	}
+	return inv
+}
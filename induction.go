@@ -0,0 +1,55 @@
+package ctl
+
+// Verdict is the result of an attempt to prove a property.
+type Verdict int
+
+const (
+	// Unknown means that the property could not be proved or falsified.
+	Unknown Verdict = iota
+	// Proved means that the property holds.
+	Proved
+	// Falsified means that the property does not hold.
+	Falsified
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Proved:
+		return "proved"
+	case Falsified:
+		return "falsified"
+	default:
+		return "unknown"
+	}
+}
+
+// KInduction tries to prove that p holds in all states that are reachable from
+// init (AG p) using k-induction for k = 1..maxK. For each k the base case
+// checks that there is no path of k-1 steps from init to a state that violates
+// p, and the inductive step checks that every path of k steps through distinct
+// states that satisfy p can only be extended to a state that satisfies p. If
+// the base case fails, a shortest path to a state that violates p is returned.
+func (m *Model) KInduction(init *BDD, p *BDD, maxK int) (Verdict, []*State) {
+	base := newUnrolling(m)
+	base.s.AddClause(base.encode(init, 0))
+	step := newUnrolling(m)
+	for k := 1; k <= maxK; k++ {
+		// Base case: p holds after k-1 steps (earlier steps are already checked).
+		if base.s.Solve(base.encode(p, k-1).Neg()) {
+			return Falsified, base.path(k - 1)
+		}
+		base.s.AddClause(base.encode(p, k-1))
+		base.step()
+
+		// Inductive step: p holds in k distinct states, but not in the next state.
+		step.s.AddClause(step.encode(p, k-1))
+		step.step()
+		for i := 0; i < k; i++ {
+			step.distinct(i, k)
+		}
+		if !step.s.Solve(step.encode(p, k).Neg()) {
+			return Proved, nil
+		}
+	}
+	return Unknown, nil
+}
@@ -12,8 +12,10 @@ func LeastSteps(init *BDD, sets []*BDD) int {
 }
 
 // GenerateExample generates a smallest path from an accepted initial state that
-// satisfies the computed specification.
+// satisfies the computed specification. Initial states outside the domain of
+// the model are ignored.
 func GenerateExample(m *Model, init *BDD, sets []*BDD) []*State {
+	init = init.And(m.domain)
 	// Find starting point (first set that intersects init).
 	path := make([]*State, 0)
 	beam := False
@@ -31,34 +33,69 @@ func GenerateExample(m *Model, init *BDD, sets []*BDD) []*State {
 
 	// Go back to the goal.
 	for ; i >= 0; i-- {
-		// Unpack beam and pick one state (this could be done much quicker).
-		states := expandStates(m.vars, false, unpackBDD(beam))
-		if len(states) == 0 {
-			panic("beam is empty")
+		s, state := pickState(m, beam)
+		path = append(path, state)
+
+		// Create BDD that contains all sets that are reachable from this state
+		// using only one transition.
+		if i > 0 {
+			beam = m.EXInv(s, sets[i-1])
 		}
+	}
 
-		// Create BDD that only accepts this state.
-		state := states[0]
-		s := True
-		for v, b := range state {
-			if !v.aux {
-				if b {
-					s = s.And(Node(v, True, False))
-				} else {
-					s = s.And(Node(v, False, True))
-				}
+	return path
+}
+
+// Pick one state from the beam. Returns a BDD that only accepts this state and
+// the processed state.
+func pickState(m *Model, beam *BDD) (*BDD, *State) {
+	// Unpack beam and pick one state (this could be done much quicker).
+	states := expandStates(m.vars, false, unpackBDD(beam))
+	if len(states) == 0 {
+		panic("beam is empty")
+	}
+
+	// Create BDD that only accepts this state.
+	state := states[0]
+	s := True
+	for v, b := range state {
+		if !v.aux {
+			if b {
+				s = s.And(Node(v, True, False))
+			} else {
+				s = s.And(Node(v, False, True))
 			}
 		}
+	}
 
-		// IMPORTANT! processState deletes keys from the state map.
-		path = append(path, processState(m, state, false))
+	// IMPORTANT! processState deletes keys from the state map.
+	return s, processState(m, state, false)
+}
 
-		// Create BDD that contains all sets that are reachable from this state
-		// using only one transition.
-		if i > 0 {
-			beam = m.EXInv(s, sets[i-1])
+// GenerateTrace generates a shortest path from an accepted initial state to a
+// state in target, given the sets of reachable states computed by
+// Model.Reachable. If there is no such path an empty path is returned.
+func GenerateTrace(m *Model, reachable []*BDD, target *BDD) []*State {
+	// Find the first set that intersects target.
+	i := 0
+	for ; i < len(reachable); i++ {
+		if reachable[i].Intersects(target) {
+			break
 		}
 	}
+	if i == len(reachable) {
+		return []*State{}
+	}
 
+	// Go back to an initial state.
+	path := make([]*State, i+1)
+	beam := reachable[i].And(target)
+	for ; i >= 0; i-- {
+		s, state := pickState(m, beam)
+		path[i] = state
+		if i > 0 {
+			beam = m.EX(reachable[i-1], s)
+		}
+	}
 	return path
 }
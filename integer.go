@@ -2,151 +2,420 @@ package ctl
 
 import "fmt"
 
-// Integer represents a bounded integer in the range 0..(2^(len(bits))-1).
+// Integer represents a bounded integer in the range 0..(2^(len(bits))-1), or
+// -2^(len(bits)-1)..2^(len(bits)-1)-1 for signed integers (two's complement).
+// Each bit is a BDD over the state variables, so arithmetic on integers
+// directly computes new bit vectors and does not introduce new variables.
 type Integer struct {
-	bits       []*Variable // Variables or bit values for each bit
-	value      uint        // Constant integer value
-	variable   bool        // Is this a variable?
-	constraint *BDD        // Constraint on the bits (if any)
+	name     string      // Name of the integer (or a description of its value)
+	vars     []*Variable // Variables for each bit (only for integer variables)
+	bits     []*BDD      // BDD for each bit (least significant bit first)
+	signed   bool        // Is the most significant bit a sign bit?
+	lo, hi   int         // Declared range (only for integer variables)
+	overflow func() *BDD // States in which computing this integer overflowed
 }
 
 // Int creates a new integer constant.
 func Int(value uint) *Integer {
-	return &Integer{[]*Variable{}, value, false, True}
+	bits := make([]*BDD, bitcount(value))
+	for n := range bits {
+		bits[n] = False
+		if (value>>n)&1 == 1 {
+			bits[n] = True
+		}
+	}
+	return &Integer{fmt.Sprintf("%v", value), nil, bits, false, 0, 0, nil}
+}
+
+// Signed creates a new signed integer constant.
+func Signed(value int) *Integer {
+	bits := make([]*BDD, signedBitcount(value, value))
+	for n := range bits {
+		bits[n] = False
+		if (value>>n)&1 == 1 {
+			bits[n] = True
+		}
+	}
+	return &Integer{fmt.Sprintf("%v", value), nil, bits, true, 0, 0, nil}
 }
 
 // Len returns the number of bits this integer uses.
 func (i *Integer) Len() int {
-	if i.variable {
-		return len(i.bits)
-	}
-	return int(bitcount(i.value))
+	return len(i.bits)
 }
 
 // Name returns the name of this integer.
 func (i *Integer) Name() string {
-	if i.variable {
-		lbl := i.bits[0].Name
-		return lbl[:len(lbl)-2]
-	}
-	return fmt.Sprintf("%v", i.value)
+	return i.name
+}
+
+// IsSigned returns if this integer uses two's complement.
+func (i *Integer) IsSigned() bool {
+	return i.signed
+}
+
+// Variable returns if this integer is a variable in the model.
+func (i *Integer) Variable() bool {
+	return len(i.vars) != 0
 }
 
-// Aux returns if this is an auxiliary integer.
+// Aux returns if this integer is not an integer variable.
+//
+// Deprecated: computed integers no longer use auxiliary variables, so this is
+// the same as !i.Variable().
 func (i *Integer) Aux() bool {
-	if i.variable {
-		return i.bits[0].aux
-	}
-	return true
+	return !i.Variable()
 }
 
 // Next returns the integer that identifies i in the next step.
 func (i *Integer) Next() *Integer {
-	if i.variable {
-		nextBits := make([]*Variable, len(i.bits))
-		for j, bit := range i.bits {
-			nextBits[j] = bit.Next()
+	nextVars := make([]*Variable, len(i.vars))
+	for n, v := range i.vars {
+		nextVars[n] = v.Next()
+	}
+	nextBits := make([]*BDD, len(i.bits))
+	for n, bit := range i.bits {
+		nextBits[n] = bit.Next()
+	}
+	name := fmt.Sprintf("next(%v)", i.name)
+	next := &Integer{name, nextVars, nextBits, i.signed, i.lo, i.hi, nil}
+	if i.overflow != nil {
+		next.overflow = cached(func() *BDD { return i.Overflow().Next() })
+	}
+	return next
+}
+
+// Create a computed integer. The result overflows if any operand overflows.
+func compute(name string, bits []*BDD, signed bool, operands ...*Integer) *Integer {
+	k := &Integer{name, nil, bits, signed, 0, 0, nil}
+	k.overflow = cached(func() *BDD {
+		overflow := False
+		for _, operand := range operands {
+			overflow = overflow.Or(operand.Overflow())
 		}
-		return &Integer{nextBits, 0, true, i.constraint.Next()}
+		return overflow
+	})
+	return k
+}
+
+// Additionally mark the computed integer k as overflowing when its value does
+// not fit in n bits.
+func (k *Integer) bounded(n int, signed bool) *Integer {
+	operands := k.overflow
+	k.overflow = cached(func() *BDD {
+		return k.inRange(bounds(n, signed)).Neg().Or(operands())
+	})
+	return k
+}
+
+// Compute the overflow of an integer only once, since integers are often
+// shared by many expressions. Unlike sync.OnceValue, the computation is
+// retried if it was interrupted (see Run).
+func cached(overflow func() *BDD) func() *BDD {
+	var result *BDD
+	return func() *BDD {
+		if result == nil {
+			result = overflow()
+		}
+		return result
+	}
+}
+
+// Overflow returns a BDD that is true in the states in which computing i
+// overflowed. An arithmetic operation overflows when its result does not fit
+// in the number of bits of its widest operand. Note that the result itself is
+// computed without losing any bits, so this only matters when it is assigned
+// to an integer of the same size.
+func (i *Integer) Overflow() *BDD {
+	if i.overflow == nil {
+		return False
+	}
+	return i.overflow()
+}
+
+// Assignment assigns a value to an integer variable in the next step (see
+// Model.AddAssign).
+type Assignment struct {
+	Target *Integer // Integer variable
+	Value  *Integer
+}
+
+// Assign returns the assignment of the given value to the integer variable i.
+func (i *Integer) Assign(value *Integer) Assignment {
+	return Assignment{i, value}
+}
+
+// BDD returns the constraint that the target has the value in the next step.
+func (a Assignment) BDD() *BDD {
+	return a.Target.Next().Eq(a.Value)
+}
+
+// Overflow returns a BDD that is true in the states in which computing the
+// value overflows or the value does not fit in the target.
+func (a Assignment) Overflow() *BDD {
+	return a.Value.Overflow().Or(a.Value.Fits(a.Target).Neg())
+}
+
+// Fits returns a BDD that is true when i is within the range of j. This is the
+// declared range if j is a variable, and otherwise the range of its bits.
+func (i *Integer) Fits(j *Integer) *BDD {
+	if j.Variable() {
+		return i.inRange(j.lo, j.hi)
+	}
+	return i.inRange(bounds(j.Len(), j.signed))
+}
+
+// Returns a BDD that is true when lo <= i <= hi.
+func (i *Integer) inRange(lo int, hi int) *BDD {
+	return Signed(lo).Leq(i).And(i.Leq(Signed(hi)))
+}
+
+// Range of values that can be represented with n bits.
+func bounds(n int, signed bool) (int, int) {
+	if signed {
+		return -(1 << (n - 1)), (1 << (n - 1)) - 1
+	}
+	return 0, (1 << n) - 1
+}
+
+// Domain returns a BDD that is true when this integer variable is within its
+// declared range. For other integers this is always true.
+func (i *Integer) Domain() *BDD {
+	if !i.Variable() {
+		return True
 	}
-	return i
+	return i.inRange(i.lo, i.hi)
 }
 
-// Bit returns a BDD representing the n-th bit.
+// Bit returns a BDD representing the n-th bit. Bits beyond the length of a
+// signed integer are equal to the sign bit.
 func (i *Integer) Bit(n int) *BDD {
-	if i.variable {
-		if n >= len(i.bits) {
-			return False
+	if n >= len(i.bits) {
+		if i.signed {
+			return i.bits[len(i.bits)-1]
 		}
-		return Node(i.bits[n], True, False)
-	}
-	if (i.value>>n)&1 == 0 {
 		return False
 	}
-	return True
+	return i.bits[n]
 }
 
-// Add returns the integer that is the result of adding i and j.
-func (i *Integer) Add(j *Integer, m *Model) *Integer {
-	return i.addNoCarry(j, m)
+// Number of bits this integer uses in two's complement. An unsigned integer
+// needs one additional (zero) sign bit.
+func (i *Integer) signedLen() int {
+	if i.signed {
+		return i.Len()
+	}
+	return i.Len() + 1
 }
 
-// Do not use carry bits.
-func (i *Integer) addNoCarry(j *Integer, m *Model) *Integer {
-	if !i.variable && !j.variable {
-		return Int(i.value + j.value)
+// Number of bits that is required to compare i and j.
+func (i *Integer) width(j *Integer) int {
+	if i.signed || j.signed {
+		return max(i.signedLen(), j.signedLen())
 	}
+	return max(i.Len(), j.Len())
+}
 
-	// i + j = k
+// Add returns the integer that is the result of adding i and j. The result has
+// one more bit than the largest operand so that no bits are lost, and it is
+// signed if either operand is signed.
+func (i *Integer) Add(j *Integer) *Integer {
 	name := fmt.Sprintf("add(%v,%v)", i.Name(), j.Name())
-	size := max(i.Len(), j.Len()) + 1
-	k := m.bin(name, uint(size), true)
+	signed := i.signed || j.signed
+	bits := ripple(i, j, false, i.width(j)+1)
+	return compute(name, bits, signed, i, j).bounded(i.width(j), signed)
+}
+
+// Sub returns the integer that is the result of subtracting j from i. The
+// result is always signed (but it overflows when the operands are unsigned and
+// the result is negative).
+func (i *Integer) Sub(j *Integer) *Integer {
+	name := fmt.Sprintf("sub(%v,%v)", i.Name(), j.Name())
+	// i - j = i + ~j + 1
+	size := max(i.signedLen(), j.signedLen()) + 1
+	bits := ripple(i, j, true, size)
+	return compute(name, bits, true, i, j).bounded(i.width(j), i.signed || j.signed)
+}
+
+// Neg returns the integer that is the result of negating i.
+func (i *Integer) Neg() *Integer {
+	name := fmt.Sprintf("neg(%v)", i.Name())
+	neg := Int(0).Sub(i)
+	return compute(name, neg.bits, true, i).bounded(i.signedLen(), true)
+}
 
-	// Implicitly compute binary addition.
-	add := k.Bit(0).Eq(i.Bit(0).Xor(j.Bit(0)))
-	for n := 1; n < size; n++ {
-		i0, j0, k0 := i.Bit(n-1), j.Bit(n-1), k.Bit(n-1)
-		i1, j1, k1 := i.Bit(n), j.Bit(n), k.Bit(n)
-		carry := i0.And(j0).Or(i0.Xor(j0).And(k0.Neg()))
-		add = add.And(k1.Eq(i1.Xor(j1).Xor(carry)))
+// Compute size bits of i + j (or i - j) using a ripple-carry adder.
+func ripple(i *Integer, j *Integer, sub bool, size int) []*BDD {
+	bits := make([]*BDD, size)
+	carry := False
+	if sub {
+		carry = True
+	}
+	for n := 0; n < size; n++ {
+		a, b := i.Bit(n), j.Bit(n)
+		if sub {
+			b = b.Neg()
+		}
+		bits[n] = a.Xor(b).Xor(carry)
+		// The next carry bit is 1 iff (a /\ b) \/ ((a xor b) /\ carry).
+		carry = a.And(b).Or(a.Xor(b).And(carry))
 	}
+	return bits
+}
 
-	// Constrain k by the addition of i and j and the constraints on i and j.
-	k.constraint = i.constraint.And(j.constraint).And(add)
-	return k
+// And returns the bitwise conjunction of i and j.
+func (i *Integer) And(j *Integer) *Integer {
+	return i.bitwise(j, "and", (*BDD).And)
+}
+
+// Or returns the bitwise disjunction of i and j.
+func (i *Integer) Or(j *Integer) *Integer {
+	return i.bitwise(j, "or", (*BDD).Or)
+}
+
+// Xor returns the bitwise exclusive disjunction of i and j.
+func (i *Integer) Xor(j *Integer) *Integer {
+	return i.bitwise(j, "xor", (*BDD).Xor)
 }
 
-// Use carry bits.
-func (i *Integer) addCarry(j *Integer, m *Model) *Integer {
-	if !i.variable && !j.variable {
-		return Int(i.value + j.value)
+// Apply a binary operator to each pair of bits. The result is signed if both
+// operands are signed, and an unsigned operand is padded with zeros.
+func (i *Integer) bitwise(j *Integer, op string, f func(*BDD, *BDD) *BDD) *Integer {
+	size := max(i.Len(), j.Len())
+	bits := make([]*BDD, size)
+	for n := range bits {
+		bits[n] = f(i.Bit(n), j.Bit(n))
 	}
+	name := fmt.Sprintf("%v(%v,%v)", op, i.Name(), j.Name())
+	return compute(name, bits, i.signed && j.signed, i, j)
+}
 
-	// i + j + c = k
-	cName := fmt.Sprintf("carry(%v,%v)", i.Name(), j.Name())
-	kName := fmt.Sprintf("add(%v,%v)", i.Name(), j.Name())
-	size := max(i.Len(), j.Len()) + 1
-	c := m.bin(cName, uint(size), true)
-	k := m.bin(kName, uint(size), true)
+// Not returns the bitwise negation of i (using the same number of bits).
+func (i *Integer) Not() *Integer {
+	bits := make([]*BDD, i.Len())
+	for n, bit := range i.bits {
+		bits[n] = bit.Neg()
+	}
+	name := fmt.Sprintf("not(%v)", i.Name())
+	return compute(name, bits, i.signed, i)
+}
 
-	// i <-> j <-> k <-> c
-	add := c.Bit(0).Eq(False) // First carry bit is 0.
-	for n := 0; n < size; n++ {
-		ib, jb, cb, kb := i.Bit(n), j.Bit(n), c.Bit(n), k.Bit(n)
-		add = add.And(ib.Eq(jb).Eq(cb).Eq(kb)) // k = i + j + c (mod 2)
-		// The next carry bit is 1 iff (ib /\ jb) \/ (ib /\ cb) \/ (jb /\ cb).
-		add = add.And(c.Bit(n + 1).Eq(ib.And(jb).Or(ib.And(cb)).Or(jb.And(cb))))
+// Shl returns i shifted to the left by k bits (using the same number of bits).
+// This overflows when the shifted value does not fit in these bits.
+func (i *Integer) Shl(k int) *Integer {
+	// Compute the exact result (k more bits) to determine the overflow.
+	bits := make([]*BDD, k, i.Len()+k)
+	for n := range bits {
+		bits[n] = False
 	}
+	bits = append(bits, i.bits...)
+	name := fmt.Sprintf("shl(%v,%v)", i.Name(), k)
+	exact := compute(name, bits, i.signed, i).bounded(i.Len(), i.signed)
+	return &Integer{name, nil, bits[:i.Len()], i.signed, 0, 0, exact.overflow}
+}
 
-	// Constrain k by the addition of i and j and the constraints on i and j.
-	k.constraint = i.constraint.And(j.constraint).And(add)
-	return k
+// Shr returns i shifted to the right by k bits. A signed integer is shifted
+// arithmetically (the sign bit is repeated).
+func (i *Integer) Shr(k int) *Integer {
+	bits := make([]*BDD, i.Len())
+	for n := range bits {
+		bits[n] = i.Bit(n + k)
+	}
+	name := fmt.Sprintf("shr(%v,%v)", i.Name(), k)
+	return compute(name, bits, i.signed, i)
+}
+
+// Slice returns the unsigned integer that consists of bits lo..hi-1 of i.
+func (i *Integer) Slice(lo int, hi int) *Integer {
+	bits := make([]*BDD, hi-lo)
+	for n := range bits {
+		bits[n] = i.Bit(lo + n)
+	}
+	name := fmt.Sprintf("%v[%v:%v]", i.Name(), lo, hi)
+	return compute(name, bits, false, i)
+}
+
+// Concat returns the integer that has the bits of j followed by the bits of i
+// (so that i is in the most significant bits). The result is signed if i is.
+func (i *Integer) Concat(j *Integer) *Integer {
+	bits := make([]*BDD, 0, i.Len()+j.Len())
+	bits = append(bits, j.bits...)
+	bits = append(bits, i.bits...)
+	name := fmt.Sprintf("concat(%v,%v)", i.Name(), j.Name())
+	return compute(name, bits, i.signed, i, j)
+}
+
+// IntIte returns the integer that is equal to then if cond holds and equal to
+// otherwise if it does not. It only overflows when the selected value does.
+func IntIte(cond *BDD, then *Integer, otherwise *Integer) *Integer {
+	bits := make([]*BDD, then.width(otherwise))
+	for n := range bits {
+		bits[n] = cond.And(then.Bit(n)).Or(cond.Neg().And(otherwise.Bit(n)))
+	}
+	name := fmt.Sprintf("ite(%v,%v)", then.Name(), otherwise.Name())
+	ite := compute(name, bits, then.signed || otherwise.signed)
+	ite.overflow = cached(func() *BDD {
+		return cond.And(then.Overflow()).Or(cond.Neg().And(otherwise.Overflow()))
+	})
+	return ite
+}
+
+// Case builds an integer from a list of ordered branches.
+type Case struct {
+	conds  []*BDD
+	values []*Integer
+}
+
+// NewCase starts a new case expression.
+func NewCase() *Case {
+	return &Case{}
+}
+
+// When adds a branch that is taken if cond holds and no earlier branch is.
+func (c *Case) When(cond *BDD, value *Integer) *Case {
+	c.conds = append(c.conds, cond)
+	c.values = append(c.values, value)
+	return c
+}
+
+// Default returns the integer that is equal to the value of the first branch
+// for which the condition holds, or equal to value if there is none.
+func (c *Case) Default(value *Integer) *Integer {
+	result := value
+	for n := len(c.conds) - 1; n >= 0; n-- {
+		result = IntIte(c.conds[n], c.values[n], result)
+	}
+	return result
 }
 
 // Eq returns a BDD that is true when i == j.
 func (i *Integer) Eq(j *Integer) *BDD {
-	size := max(i.Len(), j.Len())
+	size := i.width(j)
 	eq := True
 	for n := 0; n < size; n++ {
 		eq = eq.And(i.Bit(n).Eq(j.Bit(n)))
 	}
-	return i.constraint.And(j.constraint).And(eq)
+	return eq
 }
 
 // Lt returns a BDD that is true when i < j.
 func (i *Integer) Lt(j *Integer) *BDD {
-	size := max(i.Len(), j.Len())
-	return i.constraint.And(j.constraint).And(i.leq(j, size-1, true))
+	size := i.width(j)
+	return i.leq(j, size-1, true, i.signed || j.signed)
 }
 
 // Leq returns a BDD that is true when i <= j.
 func (i *Integer) Leq(j *Integer) *BDD {
-	size := max(i.Len(), j.Len())
-	return i.constraint.And(j.constraint).And(i.leq(j, size-1, false))
+	size := i.width(j)
+	return i.leq(j, size-1, false, i.signed || j.signed)
 }
 
-func (i *Integer) leq(j *Integer, n int, neq bool) *BDD {
+func (i *Integer) leq(j *Integer, n int, neq bool, signed bool) *BDD {
 	a, b := i.Bit(n), j.Bit(n)
+	// A set sign bit means the number is smaller.
+	if signed {
+		a, b = b, a
+	}
 	lt := a.Neg().And(b)
 	// At the 0-th bit there is a difference between <= and <.
 	if n == 0 {
@@ -157,5 +426,5 @@ func (i *Integer) leq(j *Integer, n int, neq bool) *BDD {
 		return a.Imply(b)
 	}
 	// Either the n-th bit is <, or the inequality is confirmed later.
-	return lt.Or(a.Eq(b).And(i.leq(j, n-1, neq)))
+	return lt.Or(a.Eq(b).And(i.leq(j, n-1, neq, false)))
 }
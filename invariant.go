@@ -0,0 +1,24 @@
+package ctl
+
+// CheckInvariant checks if p holds in all states that are reachable from init
+// (AG p). The reachable states are computed one step at a time, and the search
+// stops as soon as a state is found that violates p. In that case a shortest
+// path from an initial state to this state is returned.
+func (m *Model) CheckInvariant(init *BDD, p *BDD) (bool, []*State) {
+	bad := m.domain.And(p.Neg())
+	reachable := []*BDD{init.And(m.domain)}
+	frontier := reachable[0]
+	for {
+		if frontier.Intersects(bad) {
+			return false, GenerateTrace(m, reachable, bad)
+		}
+
+		// Only compute successors of newly reached states.
+		last := reachable[len(reachable)-1]
+		frontier = m.EXInv(frontier, True).And(last.Neg())
+		if frontier == False {
+			return true, nil
+		}
+		reachable = append(reachable, last.Or(frontier))
+	}
+}
@@ -1,24 +1,94 @@
 package ctl
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"weak"
 )
 
-// Total number of cached elements (used for performance analysis).
-var _cacheCounter = 0
+// Global BDD lookup table. Without a memory limit the table holds the nodes.
+// With a limit it only holds weak pointers (which is slower), so nodes that are
+// no longer referenced outside of the engine can be removed by a garbage
+// collection (see CollectGarbage).
+var _lookup = new(sync.Map)     // BDD -> *BDD
+var _weakLookup = new(sync.Map) // nodeKey -> weak.Pointer[BDD]
+var _weak = false
 
-// Global BDD lookup table. Since Go does not have weak pointers, there is no
-// way to determine if a pointer is still in use outside of this map except
-// using explicit reference counting. This is not yet implemented.
-var _lookup = new(sync.Map)
+// Number of entries in the lookup table and the apply cache.
+var _nodeCount, _applyCount = 0, 0
+
+// Since a node keeps its branches alive, the branches can be weak as well.
+type nodeKey struct {
+	v    *Variable
+	t, f weak.Pointer[BDD]
+}
 
 // Register a new BDD node reference (and get unique pointer).
 func registerNodeRef(node BDD) *BDD {
-	ref, new := _lookup.LoadOrStore(node, &node)
-	if new {
-		_cacheCounter++
+	checkInterrupt()
+	if !_weak {
+		ref, in := _lookup.LoadOrStore(node, &node)
+		if !in {
+			addNode()
+		}
+		return ref.(*BDD)
+	}
+	key := nodeKey{node.Var, weak.Make(node.True), weak.Make(node.False)}
+	ref, in := _weakLookup.Load(key)
+	if in {
+		if p := ref.(weak.Pointer[BDD]).Value(); p != nil {
+			return p
+		}
+	}
+
+	// Store a new node (or replace a node that was collected).
+	p := &node
+	_weakLookup.Store(key, weak.Make(p))
+	if !in {
+		addNode()
+	}
+	return p
+}
+
+// Count a new entry in the lookup table.
+func addNode() {
+	if _nodeCount++; _nodeCount > _peakNodes {
+		_peakNodes = _nodeCount
+	}
+	checkMemory()
+}
+
+// Move all nodes to the weak or the normal lookup table. Nodes that were
+// collected are removed.
+func setWeak(enabled bool) {
+	if enabled == _weak {
+		return
+	}
+	_weak = enabled
+	if enabled {
+		_lookup.Range(func(key, ref any) bool {
+			p := ref.(*BDD)
+			_weakLookup.Store(nodeKey{p.Var, weak.Make(p.True), weak.Make(p.False)}, weak.Make(p))
+			return true
+		})
+		_lookup.Clear()
+	} else {
+		_weakLookup.Range(func(key, ref any) bool {
+			if p := ref.(weak.Pointer[BDD]).Value(); p != nil {
+				_lookup.Store(*p, p)
+			} else {
+				_nodeCount--
+			}
+			return true
+		})
+		_weakLookup.Clear()
 	}
-	return ref.(*BDD)
 }
 
 // BDD application cache.
@@ -33,12 +103,205 @@ type applyKey struct {
 func applyCached(op uint, p *BDD, q *BDD) *BDD {
 	key := applyKey{op, p, q}
 	if result, in := _applyCache.Load(key); in {
+		_cacheHits[op]++
 		return result.(*BDD)
 	}
 
 	// Compute result.
+	checkInterrupt()
+	_cacheMisses[op]++
 	result := p.Apply(op, q)
 	_applyCache.Store(key, result)
-	_cacheCounter++
+	_applyCount++
+	checkMemory()
 	return result
 }
+
+// CollectGarbage clears the apply cache and removes all nodes that are no
+// longer referenced from the lookup table.
+func CollectGarbage() {
+	// The apply cache references nodes, so it has to be cleared first.
+	_applyCache.Clear()
+	_applyCount = 0
+	_gcRuns++
+	enabled := _weak
+	setWeak(true)
+	runtime.GC()
+	_weakLookup.Range(func(key, ref any) bool {
+		if ref.(weak.Pointer[BDD]).Value() == nil {
+			_weakLookup.Delete(key)
+			_nodeCount--
+		}
+		return true
+	})
+	setWeak(enabled)
+}
+
+// ErrMemoryLimit is returned when an operation is aborted because the BDD
+// engine exceeds its memory limit (the error is a *MemoryLimitError, use
+// errors.Is to check for it).
+var ErrMemoryLimit = errors.New("BDD memory limit exceeded")
+
+// MemoryLimitError reports the memory limit and usage of the BDD engine when an
+// operation is aborted.
+type MemoryLimitError struct {
+	NodeLimit int // Maximum number of entries (0 if unlimited)
+	ByteLimit int // Maximum number of bytes (0 if unlimited)
+	Entries   int // Entries in the lookup table and the apply cache
+	Bytes     int // Estimated number of bytes used by the entries
+}
+
+func (e *MemoryLimitError) Error() string {
+	return fmt.Sprintf("%v (%v entries, %v bytes)", ErrMemoryLimit, e.Entries, e.Bytes)
+}
+
+// Is reports that the error is ErrMemoryLimit.
+func (e *MemoryLimitError) Is(target error) bool {
+	return target == ErrMemoryLimit
+}
+
+// Memory limit (0 means no limit).
+var _nodeLimit, _byteLimit = 0, 0
+
+// Rough estimates of the memory used by a node and an apply cache entry.
+const nodeBytes, applyBytes = 200, 120
+
+// SetMemoryLimit sets the maximum number of entries in the lookup table and the
+// apply cache together, and the maximum (estimated) number of bytes they use. A
+// limit of 0 disables it. When the limit is exceeded, a garbage collection is
+// attempted. If less than a quarter of the limit is freed, the operation is
+// aborted with a *MemoryLimitError. The variables cannot be reordered during an
+// operation, so a fixpoint computation reorders them (see Reorder) and then
+// tries the iteration again. Operations that return an error (such as AddRule,
+// AU and Save) return the *MemoryLimitError, and so do Run and the Context
+// variants of EF, EG, EU and Reachable. All other operations (such as EF,
+// CheckInvariant, BMC and operations on BDDs and integers) panic with it, so
+// when a limit is set they should be called within Run. Note that the lookup
+// table is slower while a limit is set.
+func SetMemoryLimit(nodes int, bytes int) {
+	_nodeLimit, _byteLimit = nodes, bytes
+	setWeak(nodes > 0 || bytes > 0)
+}
+
+// Check if the memory used is more than the given fraction of the limit.
+func exceedsLimit(fraction float64) bool {
+	entries := _nodeCount + _applyCount
+	bytes := _nodeCount*nodeBytes + _applyCount*applyBytes
+	return _nodeLimit > 0 && float64(entries) > fraction*float64(_nodeLimit) ||
+		_byteLimit > 0 && float64(bytes) > fraction*float64(_byteLimit)
+}
+
+// Collect garbage if the memory limit is exceeded, and abort if this does not
+// free enough memory. Since this is called during operations, the variables
+// cannot be reordered here.
+func checkMemory() {
+	if !exceedsLimit(1) {
+		return
+	}
+	CollectGarbage()
+	if exceedsLimit(0.75) {
+		abort(&MemoryLimitError{_nodeLimit, _byteLimit, _nodeCount + _applyCount,
+			_nodeCount*nodeBytes + _applyCount*applyBytes})
+	}
+}
+
+// Return the error of an operation that is aborted outside of Run, instead of
+// panicking. This should be deferred by exported operations that return an
+// error.
+func recoverLimit(err *error) {
+	if p := recover(); p != nil {
+		e, ok := p.(*MemoryLimitError)
+		if !ok {
+			panic(p)
+		}
+		*err = e
+	}
+}
+
+// A computation that can be interrupted (see Run). Since the runs are stored
+// per goroutine, a computation is only interrupted by its own context (and the
+// contexts of enclosing runs in the same goroutine).
+type run struct {
+	ctx  context.Context
+	prev *run
+}
+
+var _runs = new(sync.Map) // goroutine ID -> *run
+var _running atomic.Int32 // Number of runs in all goroutines
+var _checks atomic.Uint32
+
+// ID of the current goroutine, which is only available from its stack trace
+// (that starts with "goroutine 1 [running]:").
+func goroutineID() uint64 {
+	var buf [64]byte
+	trace := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	id, _ := strconv.ParseUint(string(trace[:bytes.IndexByte(trace, ' ')]), 10, 64)
+	return id
+}
+
+// Interrupt is used to unwind a computation when its context is cancelled or
+// the memory limit is exceeded.
+type interrupt struct {
+	err error
+}
+
+func (i interrupt) Error() string {
+	return i.err.Error()
+}
+
+// Abort the running computation with the given error, which is returned by
+// Run. Outside of Run the error itself is the value of the panic.
+func abort(err error) {
+	if _, in := _runs.Load(goroutineID()); in {
+		panic(interrupt{err})
+	}
+	panic(err)
+}
+
+// Check if the running computation is cancelled. Since this is called in every
+// recursion of Apply, the context is only checked every 1024 calls.
+func checkInterrupt() {
+	if _running.Load() == 0 || _checks.Add(1)%1024 != 0 {
+		return
+	}
+	if r, in := _runs.Load(goroutineID()); in {
+		for r := r.(*run); r != nil; r = r.prev {
+			if err := r.ctx.Err(); err != nil {
+				panic(interrupt{err})
+			}
+		}
+	}
+}
+
+// Run runs f such that the BDD operations in f are interrupted when ctx is
+// cancelled or the memory limit is exceeded, in which case the error of the
+// context or ErrMemoryLimit is returned. This is required to handle the memory
+// limit of operations that do not return an error (see SetMemoryLimit). Calls in
+// different goroutines do not interrupt each other, but note that the BDD
+// engine is not safe for concurrent use.
+func Run(ctx context.Context, f func()) (err error) {
+	id := goroutineID()
+	r := &run{ctx, nil}
+	if prev, in := _runs.Load(id); in {
+		r.prev = prev.(*run)
+	}
+	_runs.Store(id, r)
+	_running.Add(1)
+	defer func() {
+		if r.prev != nil {
+			_runs.Store(id, r.prev)
+		} else {
+			_runs.Delete(id)
+		}
+		_running.Add(-1)
+		if p := recover(); p != nil {
+			i, ok := p.(interrupt)
+			if !ok {
+				panic(p)
+			}
+			err = i.err
+		}
+	}()
+	f()
+	return nil
+}
@@ -1,7 +1,10 @@
 package ctl
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 )
 
 // Variable identifies a boolean variable.
@@ -77,12 +80,61 @@ func (v *Variable) Norm() *Variable {
 	return v
 }
 
+// BitOrder determines the order of the bits of an integer in the variable
+// ordering.
+type BitOrder int
+
+const (
+	// LSBFirst puts the least significant bit first.
+	LSBFirst BitOrder = iota
+	// MSBFirst puts the most significant bit first (this is usually better for
+	// comparisons such as Leq).
+	MSBFirst
+)
+
 // Model describes a set of variables and transitions.
 type Model struct {
-	vars  []*Variable // All variables in the model
-	ints  []*Integer  // All integers in the model
-	order *Variable   // Variable ordering
-	trans *BDD
+	vars   []*Variable // All variables in the model
+	ints   []*Integer  // All integers in the model
+	enums  []*Enum     // All enums in the model
+	order  **Variable  // Variable ordering (outside of the model, see Var)
+	domain *BDD        // Invariant that keeps all integers within their range
+	rules  []*BDD      // All transitions (restricted to the domain)
+	raw    []*BDD      // All transitions as they were added
+	names  []string    // Name of each transition
+	trans  *BDD        // Disjunction of all transitions
+	init   *BDD        // Initial states
+
+	bitOrder       BitOrder       // Order of the bits of new integers
+	deadlockPolicy DeadlockPolicy // Treatment of deadlocks by AX, AU, etc.
+
+	checkOverflow bool // Record states in which transitions overflow
+	overflow      *BDD // States in which a transition overflows
+
+	progress  func(Progress)   // Called after each fixpoint iteration
+	fixpoints []*FixpointStats // Statistics of fixpoint computations
+}
+
+// DomainError reports the integers that a transition can push outside of their
+// declared range (and the enums it can push outside of their values).
+type DomainError struct {
+	Rule       string            // Name of the transition
+	Violations []DomainViolation // Violated range of each integer or enum
+}
+
+// DomainViolation describes the states from which a transition can push an
+// integer or enum outside of its domain.
+type DomainViolation struct {
+	Name   string // Name of the integer or enum
+	States *BDD   // States from which the range can be violated
+}
+
+func (e *DomainError) Error() string {
+	names := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		names[i] = v.Name
+	}
+	return fmt.Sprintf("transition %v can push %v outside of the domain", e.Rule, strings.Join(names, ", "))
 }
 
 // NewModel creates a new model.
@@ -90,6 +142,18 @@ func NewModel() *Model {
 	return &Model{
 		make([]*Variable, 0),
 		make([]*Integer, 0),
+		make([]*Enum, 0),
+		new(*Variable),
+		True,
+		make([]*BDD, 0),
+		make([]*BDD, 0),
+		make([]string, 0),
+		False,
+		True,
+		LSBFirst,
+		FinitePaths,
+		false,
+		False,
 		nil,
 		nil}
 }
@@ -100,9 +164,11 @@ func (m *Model) Var(name string, aux bool) *Variable {
 	seq := uint(2*len(m.vars) + 1)
 	i1, i2 := seq, seq+1
 
+	// The variables do not refer to the model itself, since the lookup table
+	// holds them strongly (which would keep all BDDs of the model alive).
 	nextName := fmt.Sprintf("next(%v)", name)
-	v := &Variable{name, i1, aux, false, nil, nil, &m.order}
-	v.twin = &Variable{nextName, i2, aux, true, v, nil, &m.order}
+	v := &Variable{name, i1, aux, false, nil, nil, m.order}
+	v.twin = &Variable{nextName, i2, aux, true, v, nil, m.order}
 	m.vars = append(m.vars, v)
 	return v
 }
@@ -115,40 +181,218 @@ func (m *Model) Bool(name string) *BDD {
 
 // Int creates a new integer variable that contains the given upperbound.
 func (m *Model) Int(name string, upb uint) *Integer {
-	return m.bin(name, bitcount(upb), false)
+	return m.bin(name, bitcount(upb), false, 0, int(upb))
+}
+
+// SignedInt creates a new signed integer variable that contains the given
+// lowerbound and upperbound.
+func (m *Model) SignedInt(name string, lo int, hi int) *Integer {
+	return m.bin(name, signedBitcount(lo, hi), true, lo, hi)
+}
+
+// IntGroup creates new integer variables that contain the given upperbound.
+// The bits of these integers are interleaved in the variable ordering, which
+// is much better for operations that involve several of them (such as a.Eq(b)
+// or a.Add(b)).
+func (m *Model) IntGroup(names []string, upb uint) []*Integer {
+	return m.group(names, bitcount(upb), false, 0, int(upb))
+}
+
+// SetBitOrder sets the order in which the bits of integers that are created
+// after this call appear in the variable ordering.
+func (m *Model) SetBitOrder(order BitOrder) {
+	m.bitOrder = order
 }
 
-// bin creates a new integer variable with the given number of bits. Auxiliary
-// binary numbers are created for arithmetic operations, but these do not belong
-// to the state (when generating counter examples etc.).
-func (m *Model) bin(name string, n uint, aux bool) *Integer {
-	bits := make([]*Variable, n)
-	for i := range bits {
-		bits[i] = m.Var(fmt.Sprintf("%v@%v", name, i), aux)
+// bin creates a new integer variable with the given number of bits and range.
+// The range is added to the domain of the model.
+func (m *Model) bin(name string, n uint, signed bool, lo int, hi int) *Integer {
+	return m.group([]string{name}, n, signed, lo, hi)[0]
+}
+
+// group creates integer variables with interleaved bits. Since the variable
+// ordering follows the order of declaration, the bits are declared one bit
+// position at a time.
+func (m *Model) group(names []string, n uint, signed bool, lo int, hi int) []*Integer {
+	vars := make([][]*Variable, len(names))
+	for j := range names {
+		vars[j] = make([]*Variable, n)
+	}
+	for k := 0; k < int(n); k++ {
+		i := k
+		if m.bitOrder == MSBFirst {
+			i = int(n) - 1 - k
+		}
+		for j, name := range names {
+			vars[j][i] = m.Var(fmt.Sprintf("%v@%v", name, i), false)
+		}
 	}
-	integer := &Integer{bits, 0, true, True}
-	m.ints = append(m.ints, integer)
-	return integer
+
+	integers := make([]*Integer, len(names))
+	for j, name := range names {
+		bits := make([]*BDD, n)
+		for i, v := range vars[j] {
+			bits[i] = Node(v, True, False)
+		}
+		integers[j] = &Integer{name, vars[j], bits, signed, lo, hi, nil}
+		m.ints = append(m.ints, integers[j])
+		m.restrict(integers[j].Domain())
+	}
+	return integers
 }
 
-// Add adds a new transition.
-func (m *Model) Add(condition *BDD, constraint *BDD) {
-	if m.trans == nil {
-		m.trans = condition.And(constraint)
-	} else {
-		m.trans = m.trans.Or(condition.And(constraint))
+// Add the range of a new variable to the domain of the model. The transitions
+// that were already added are restricted to the new domain as well (but see
+// CheckDomain).
+func (m *Model) restrict(domain *BDD) {
+	m.domain = m.domain.And(domain)
+	for i, rule := range m.rules {
+		m.rules[i] = rule.And(domain).And(domain.Next())
 	}
+	m.trans = m.trans.And(domain).And(domain.Next())
+}
+
+// Domain returns the states in which all integers are within their range and
+// all enums have a valid value.
+func (m *Model) Domain() *BDD {
+	return m.domain
+}
+
+// Add adds a new transition. Only transitions between states in the domain of
+// the model are added. If there are states from which the transition can lead
+// outside the range of an integer (or enum), a DomainError is returned that
+// lists all such integers (the other transitions are still added). Variables
+// that are declared later are only checked by CheckDomain. The transition is
+// named after its position (rule1, rule2, etc.).
+func (m *Model) Add(condition *BDD, constraint *BDD) error {
+	return m.AddRule(fmt.Sprintf("rule%v", len(m.rules)+1), condition, constraint)
+}
+
+// AddRule is like Add, but gives the transition a name (which is used to
+// describe the steps of traces and state graphs).
+func (m *Model) AddRule(name string, condition *BDD, constraint *BDD) (err error) {
+	defer recoverLimit(&err)
+	raw := condition.And(constraint)
+	rule := raw.And(m.domain).And(m.domain.Next())
+	trans := m.trans.Or(rule)
+	m.raw = append(m.raw, raw)
+	m.rules = append(m.rules, rule)
+	m.names = append(m.names, name)
+	m.trans = trans
+
+	if err := m.checkDomain(len(m.raw) - 1); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddAssign adds a transition in which the constraint holds and each of the
+// assignments is made. If overflow checking is enabled, the states in which the
+// condition holds but an assignment overflows are recorded (see Overflows). A
+// nondeterministic choice between assignments can be added as transitions with
+// the same name.
+func (m *Model) AddAssign(name string, condition *BDD, constraint *BDD, assignments ...Assignment) (err error) {
+	defer recoverLimit(&err)
+	overflow := m.overflow
+	for _, a := range assignments {
+		constraint = constraint.And(a.BDD())
+		if m.checkOverflow {
+			overflow = overflow.Or(condition.And(m.domain).And(a.Overflow()))
+		}
+	}
+	m.overflow = overflow
+	return m.AddRule(name, condition, constraint)
+}
+
+// CheckDomain checks all transitions against the domain of all integers and
+// enums, including variables that were declared after a transition was added
+// (such a transition can push them anywhere unless it keeps them unchanged). A
+// DomainError is returned for each transition that can leave the domain
+// (combined with errors.Join).
+func (m *Model) CheckDomain() (err error) {
+	defer recoverLimit(&err)
+	errs := make([]error, 0)
+	for r := range m.raw {
+		if err := m.checkDomain(r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Get the states from which the r-th transition can push each integer or enum
+// outside of its domain.
+func (m *Model) checkDomain(r int) *DomainError {
+	transition := m.raw[r].And(m.domain)
+	violations := make([]DomainViolation, 0)
+	check := func(name string, domain *BDD) {
+		if states := m.existsNext(transition.And(domain.Neg())); states != False {
+			violations = append(violations, DomainViolation{name, states})
+		}
+	}
+	for _, i := range m.ints {
+		check(i.Name(), i.Next().Domain())
+	}
+	for _, e := range m.enums {
+		check(e.Name(), e.Next().Domain())
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return &DomainError{m.names[r], violations}
+}
+
+// SetInit sets the initial states of the model (these are only used when the
+// model is saved, all methods take the initial states as an argument).
+func (m *Model) SetInit(init *BDD) {
+	m.init = init
+}
+
+// Init returns the initial states of the model (all states by default).
+func (m *Model) Init() *BDD {
+	return m.init
+}
+
+// Rules returns the transition of each call to Add (restricted to the domain).
+func (m *Model) Rules() []*BDD {
+	return append([]*BDD{}, m.rules...)
+}
+
+// RuleNames returns the name of each transition.
+func (m *Model) RuleNames() []string {
+	return append([]string{}, m.names...)
+}
+
+// CheckOverflow enables or disables overflow checking for transitions that are
+// added after this call. If enabled, the model records all states in which the
+// condition of a transition holds but the value of one of its assignments (see
+// AddAssign) overflows or does not fit in the range of its target.
+func (m *Model) CheckOverflow(enabled bool) {
+	m.checkOverflow = enabled
+}
+
+// Overflows returns the states that are reachable from init in which the
+// condition of a transition holds but an assignment overflows. This requires
+// overflow checking to be enabled before the transitions are added. Only the
+// assignments passed to AddAssign are checked (constraints passed to Add are
+// not, since the assignments in a BDD cannot be recovered).
+func (m *Model) Overflows(init *BDD) *BDD {
+	reachable := m.Reachable(init)
+	return reachable[len(reachable)-1].And(m.overflow)
+}
+
+// Eliminate all next variables from p.
+func (m *Model) existsNext(p *BDD) *BDD {
+	for _, v := range m.vars {
+		p = p.Exists(v.Next())
+	}
+	return p
 }
 
 // EX returns the states in start that transition to next in one step.
 func (m *Model) EX(start *BDD, goal *BDD) *BDD {
 	// A state is included if there exists next(a1)...next(an) such that:
-	states := start.And(m.trans).And(goal.Next())
-	// Eliminate exists.
-	for _, v := range m.vars {
-		states = states.Exists(v.Next())
-	}
-	return states
+	return m.existsNext(start.And(m.trans).And(goal.Next()))
 }
 
 // EXInv returns the states in goal that transition from start in one step.
@@ -163,48 +407,38 @@ func (m *Model) EXInv(start *BDD, goal *BDD) *BDD {
 	return states.Norm()
 }
 
+// Reachable returns all states that are reachable from init. The states that
+// are reachable in at most n steps are returned in the n-th index. It panics if
+// the memory limit is exceeded (ReachableContext returns the error instead).
+func (m *Model) Reachable(init *BDD) []*BDD {
+	return must(m.ReachableContext(context.Background(), init))
+}
+
 // EG returns states for which there exists a path of n steps such that for each
 // step a condition holds. The states for which there exists a path of n steps
 // that satisfy this condition is returned in the n-th index. If the final set
-// is empty there is no path for which the condition globally holds.
+// is empty there is no path for which the condition globally holds. Use
+// EGContext to get an error when the memory limit is exceeded.
 func (m *Model) EG(condition *BDD) []*BDD {
-	result := make([]*BDD, 0)
-	last := condition
-	var next *BDD
-	for {
-		result = append(result, last)
-		next = last.And(m.EX(condition, last))
-		if next.Equals(last) {
-			return result
-		}
-		last = next
-	}
+	return must(m.EGContext(context.Background(), condition))
 }
 
 // EU returns all states that can transition to goal such that a given condition
 // holds for all steps. The states for which this is possible in n steps is
-// returned in the n-th index.
+// returned in the n-th index. Use EUContext to get an error when the memory
+// limit is exceeded.
 func (m *Model) EU(step *BDD, goal *BDD) []*BDD {
-	result := make([]*BDD, 0)
-	last := goal
-	var next *BDD
-	for {
-		result = append(result, last)
-		next = last.Or(m.EX(step, last))
-		if next.Equals(last) {
-			return result
-		}
-		last = next
-	}
+	return must(m.EUContext(context.Background(), step, goal))
 }
 
-// EF collects all state sets that can transition to goal in n steps.
+// EF collects all state sets that can transition to goal in n steps. Use
+// EFContext to get an error when the memory limit is exceeded.
 func (m *Model) EF(goal *BDD) []*BDD {
-	return m.EU(True, goal)
+	return must(m.EFContext(context.Background(), goal))
 }
 
 // PrintStates is a utility to print all states in the given BDD as TSV data.
 func (m *Model) PrintStates(p *BDD, aux bool) {
-	states := expandStates(m.vars, aux, unpackBDD(p))
+	states := expandStates(m.vars, aux, unpackBDD(p.And(m.domain)))
 	printStates(processStates(m, states, aux))
 }
@@ -0,0 +1,519 @@
+package ctl
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// FileFormat determines how models and BDDs are saved.
+type FileFormat int
+
+const (
+	// BinaryFormat is a compact binary format.
+	BinaryFormat FileFormat = iota
+	// TextFormat is a line based text format (one record per line).
+	TextFormat
+)
+
+// Version of the file format.
+const fileVersion = 1
+
+// Magic bytes at the start of a binary file.
+const binaryMagic = "\x00CTL"
+
+// ErrInvalidFile is returned when a file cannot be loaded because it is
+// corrupt, has an unsupported version, or does not match the model.
+var ErrInvalidFile = errors.New("invalid file")
+
+// Save writes the model to w: the variables and their ordering, the integers,
+// enums, transitions (with their names), initial states and settings. The file
+// starts with a versioned header and ends with a checksum.
+func (m *Model) Save(w io.Writer, format FileFormat) error {
+	e := newEncoder(w, format, "model")
+	e.num(len(m.vars))
+	e.end()
+	for _, v := range m.vars {
+		e.str(v.Name)
+		e.num(int(v.seq))
+		e.bool(v.aux)
+		e.end()
+	}
+
+	index := m.varIndex()
+	e.num(len(m.ints))
+	e.end()
+	for _, i := range m.ints {
+		e.str(i.name)
+		e.bool(i.signed)
+		e.num(i.lo)
+		e.num(i.hi)
+		e.vars(index, i.vars)
+		e.end()
+	}
+	e.num(len(m.enums))
+	e.end()
+	for _, en := range m.enums {
+		e.str(en.name)
+		e.num(int(en.encoding))
+		e.num(len(en.values))
+		for _, value := range en.values {
+			e.str(value)
+		}
+		e.vars(index, en.vars)
+		e.end()
+	}
+	e.num(int(m.bitOrder))
+	e.num(int(m.deadlockPolicy))
+	e.bool(m.checkOverflow)
+	e.end()
+
+	// Transitions and other sets of states.
+	ids := e.bdds(index, append([]*BDD{m.domain, m.init, m.overflow}, m.raw...))
+	e.num(ids[m.domain])
+	e.num(ids[m.init])
+	e.num(ids[m.overflow])
+	e.num(len(m.raw))
+	e.end()
+	for i, rule := range m.raw {
+		e.str(m.names[i])
+		e.num(ids[rule])
+		e.end()
+	}
+	return e.close()
+}
+
+// Load reads a model that was written by Save (in either format).
+func Load(r io.Reader) (_ *Model, err error) {
+	defer recoverLimit(&err)
+	d := newDecoder(r, "model")
+	m := NewModel()
+	n := d.count()
+	d.end()
+	// The variables can be reordered, so the sequence numbers are a permutation
+	// of the odd numbers below 2n.
+	used := make(map[int]bool)
+	for i := 0; i < n && d.err == nil; i++ {
+		name, seq, aux := d.str(), d.num(), d.bool()
+		d.end()
+		if seq%2 != 1 || seq >= 2*n || used[seq] {
+			d.fail("invalid sequence number %v of variable %v", seq, name)
+		}
+		used[seq] = true
+		v := m.Var(name, aux)
+		v.seq, v.twin.seq = uint(seq), uint(seq+1)
+	}
+
+	n = d.count()
+	d.end()
+	for i := 0; i < n && d.err == nil; i++ {
+		name, signed, lo, hi := d.str(), d.bool(), d.num(), d.num()
+		vars := d.vars(m)
+		d.end()
+		bits := make([]*BDD, len(vars))
+		for j, v := range vars {
+			bits[j] = Node(v, True, False)
+		}
+		m.ints = append(m.ints, &Integer{name, vars, bits, signed, lo, hi, nil})
+	}
+	n = d.count()
+	d.end()
+	for i := 0; i < n && d.err == nil; i++ {
+		name, encoding := d.str(), Encoding(d.num())
+		values := make([]string, 0)
+		for j, n := 0, d.count(); j < n && d.err == nil; j++ {
+			values = append(values, d.str())
+		}
+		vars := d.vars(m)
+		d.end()
+		m.enums = append(m.enums, &Enum{name, values, vars, encoding})
+	}
+	m.bitOrder = BitOrder(d.num())
+	m.deadlockPolicy = DeadlockPolicy(d.num())
+	m.checkOverflow = d.bool()
+	d.end()
+
+	nodes := d.bdds(m)
+	m.domain, m.init, m.overflow = d.bdd(nodes), d.bdd(nodes), d.bdd(nodes)
+	n = d.count()
+	d.end()
+	for i := 0; i < n && d.err == nil; i++ {
+		name, rule := d.str(), d.bdd(nodes)
+		d.end()
+		restricted := rule.And(m.domain).And(m.domain.Next())
+		m.raw = append(m.raw, rule)
+		m.rules = append(m.rules, restricted)
+		m.names = append(m.names, name)
+		m.trans = m.trans.Or(restricted)
+	}
+	if err := d.close(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WriteBDDs writes the given BDDs to w. The BDDs can only be read by a model
+// with the same variables.
+func (m *Model) WriteBDDs(w io.Writer, format FileFormat, roots ...*BDD) error {
+	e := newEncoder(w, format, "bdd")
+	e.num(len(m.vars))
+	for _, v := range m.vars {
+		e.str(v.Name)
+	}
+	e.end()
+	ids := e.bdds(m.varIndex(), roots)
+	e.num(len(roots))
+	for _, root := range roots {
+		e.num(ids[root])
+	}
+	e.end()
+	return e.close()
+}
+
+// ReadBDDs reads BDDs that were written by WriteBDDs. An error is returned if
+// the variables of this model are different.
+func (m *Model) ReadBDDs(r io.Reader) (_ []*BDD, err error) {
+	defer recoverLimit(&err)
+	d := newDecoder(r, "bdd")
+	if n := d.count(); d.err == nil && n != len(m.vars) {
+		d.fail("expected %v variables instead of %v", len(m.vars), n)
+	}
+	for i := 0; i < len(m.vars) && d.err == nil; i++ {
+		if name := d.str(); d.err == nil && name != m.vars[i].Name {
+			d.fail("expected variable %v instead of %v", m.vars[i].Name, name)
+		}
+	}
+	d.end()
+	nodes := d.bdds(m)
+	roots := make([]*BDD, 0)
+	for i, n := 0, d.count(); i < n && d.err == nil; i++ {
+		roots = append(roots, d.bdd(nodes))
+	}
+	d.end()
+	if err := d.close(); err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
+
+// Get the index of each (normal) variable.
+func (m *Model) varIndex() map[*Variable]int {
+	index := make(map[*Variable]int, len(m.vars))
+	for i, v := range m.vars {
+		index[v] = i
+	}
+	return index
+}
+
+// An encoder writes records of numbers and strings, followed by a checksum.
+type encoder struct {
+	w      io.Writer
+	crc    hash.Hash32
+	binary bool
+	buf    []byte // Current record
+	err    error
+}
+
+func newEncoder(w io.Writer, format FileFormat, kind string) *encoder {
+	e := &encoder{w, crc32.NewIEEE(), format == BinaryFormat, nil, nil}
+	if e.binary {
+		e.buf = append(e.buf, binaryMagic...)
+	}
+	e.str("ctl-" + kind)
+	e.num(fileVersion)
+	e.end()
+	return e
+}
+
+func (e *encoder) sep() {
+	if !e.binary && len(e.buf) > 0 {
+		e.buf = append(e.buf, ' ')
+	}
+}
+
+func (e *encoder) num(n int) {
+	e.sep()
+	if e.binary {
+		e.buf = binary.AppendVarint(e.buf, int64(n))
+	} else {
+		e.buf = strconv.AppendInt(e.buf, int64(n), 10)
+	}
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.num(1)
+	} else {
+		e.num(0)
+	}
+}
+
+func (e *encoder) str(s string) {
+	e.sep()
+	if e.binary {
+		e.buf = binary.AppendUvarint(e.buf, uint64(len(s)))
+		e.buf = append(e.buf, s...)
+	} else {
+		e.buf = strconv.AppendQuote(e.buf, s)
+	}
+}
+
+// Write a list of variables by index.
+func (e *encoder) vars(index map[*Variable]int, vars []*Variable) {
+	e.num(len(vars))
+	for _, v := range vars {
+		e.num(index[v])
+	}
+}
+
+// Write the nodes of the given BDDs (each node after its branches) and return
+// their ids (0 and 1 are false and true).
+func (e *encoder) bdds(index map[*Variable]int, roots []*BDD) map[*BDD]int {
+	ids := map[*BDD]int{False: 0, True: 1}
+	nodes := make([]*BDD, 0)
+	var visit func(p *BDD)
+	visit = func(p *BDD) {
+		if _, in := ids[p]; !in {
+			visit(p.True)
+			visit(p.False)
+			ids[p] = len(nodes) + 2
+			nodes = append(nodes, p)
+		}
+	}
+	for _, root := range roots {
+		visit(root)
+	}
+	e.num(len(nodes))
+	e.end()
+	for _, p := range nodes {
+		e.num(index[p.Var.Norm()])
+		e.bool(p.Var.next)
+		e.num(ids[p.True])
+		e.num(ids[p.False])
+		e.end()
+	}
+	return ids
+}
+
+// Write the current record.
+func (e *encoder) end() {
+	if !e.binary {
+		e.buf = append(e.buf, '\n')
+	}
+	if e.err == nil {
+		e.crc.Write(e.buf)
+		_, e.err = e.w.Write(e.buf)
+	}
+	e.buf = e.buf[:0]
+}
+
+// Write the checksum.
+func (e *encoder) close() error {
+	e.num(int(e.crc.Sum32()))
+	e.end()
+	return e.err
+}
+
+// A decoder reads records that were written by an encoder. The first error is
+// kept, after which all values are zero.
+type decoder struct {
+	r      *bufio.Reader
+	crc    hash.Hash32
+	binary bool
+	line   string // Rest of the current line (text format)
+	fresh  bool   // Whether the next line should be read (text format)
+	err    error
+}
+
+func newDecoder(r io.Reader, kind string) *decoder {
+	d := &decoder{bufio.NewReader(r), crc32.NewIEEE(), false, "", true, nil}
+	if magic, _ := d.r.Peek(len(binaryMagic)); string(magic) == binaryMagic {
+		d.binary = true
+		d.r.Discard(len(binaryMagic))
+		d.crc.Write(magic)
+	}
+	if header := d.str(); d.err == nil && header != "ctl-"+kind {
+		d.fail("not a %v file", kind)
+	}
+	if version := d.num(); d.err == nil && version != fileVersion {
+		d.fail("unsupported version %v", version)
+	}
+	d.end()
+	return d
+}
+
+func (d *decoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: %v", ErrInvalidFile, fmt.Sprintf(format, args...))
+	}
+}
+
+// Get the next token of the current line (text format).
+func (d *decoder) token(quoted bool) string {
+	if d.fresh {
+		line, err := d.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			d.fail("unexpected end of file")
+			return ""
+		}
+		d.crc.Write([]byte(line))
+		d.line, d.fresh = strings.TrimSuffix(line, "\n"), false
+	}
+	d.line = strings.TrimLeft(d.line, " ")
+	if quoted {
+		token, err := strconv.QuotedPrefix(d.line)
+		if err != nil {
+			d.fail("expected a string")
+			return ""
+		}
+		d.line = d.line[len(token):]
+		s, _ := strconv.Unquote(token)
+		return s
+	}
+	i := strings.IndexByte(d.line, ' ')
+	if i < 0 {
+		i = len(d.line)
+	}
+	token := d.line[:i]
+	d.line = d.line[i:]
+	return token
+}
+
+// Read a byte (binary format).
+func (d *decoder) ReadByte() (byte, error) {
+	b, err := d.r.ReadByte()
+	if err == nil {
+		d.crc.Write([]byte{b})
+	}
+	return b, err
+}
+
+func (d *decoder) num() int {
+	if d.err != nil {
+		return 0
+	}
+	if d.binary {
+		n, err := binary.ReadVarint(d)
+		if err != nil {
+			d.fail("unexpected end of file")
+		}
+		return int(n)
+	}
+	n, err := strconv.Atoi(d.token(false))
+	if err != nil {
+		d.fail("expected a number")
+	}
+	return n
+}
+
+// Read a non-negative number that is used as a length. Since the number is not
+// verified until the checksum is read, it must not be used to allocate memory
+// up front (elements are appended while they are read instead).
+func (d *decoder) count() int {
+	n := d.num()
+	if n < 0 || n > 1<<30 {
+		d.fail("invalid count %v", n)
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) bool() bool {
+	return d.num() != 0
+}
+
+func (d *decoder) str() string {
+	if d.err != nil {
+		return ""
+	}
+	if !d.binary {
+		return d.token(true)
+	}
+	n, err := binary.ReadUvarint(d)
+	if err != nil || n > 1<<20 {
+		d.fail("invalid string")
+		return ""
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		d.fail("unexpected end of file")
+		return ""
+	}
+	d.crc.Write(buf)
+	return string(buf)
+}
+
+// Read a list of variables of m by index.
+func (d *decoder) vars(m *Model) []*Variable {
+	vars := make([]*Variable, 0)
+	for i, n := 0, d.count(); i < n && d.err == nil; i++ {
+		if j := d.num(); j >= 0 && j < len(m.vars) {
+			vars = append(vars, m.vars[j])
+		} else {
+			d.fail("invalid variable %v", j)
+			return nil
+		}
+	}
+	return vars
+}
+
+// Read the nodes of BDDs over the variables of m (see encoder.bdds).
+func (d *decoder) bdds(m *Model) []*BDD {
+	nodes := []*BDD{False, True}
+	n := d.count()
+	d.end()
+	for i := 0; i < n && d.err == nil; i++ {
+		v, next, t, f := d.num(), d.bool(), d.num(), d.num()
+		d.end()
+		if v < 0 || v >= len(m.vars) || t < 0 || t >= len(nodes) || f < 0 || f >= len(nodes) {
+			d.fail("invalid node")
+			break
+		}
+		variable := m.vars[v]
+		if next {
+			variable = variable.Next()
+		}
+		if nodes[t].Node() && !variable.Lt(nodes[t].Var) ||
+			nodes[f].Node() && !variable.Lt(nodes[f].Var) {
+			d.fail("node out of order")
+			break
+		}
+		nodes = append(nodes, Node(variable, nodes[t], nodes[f]))
+	}
+	return nodes
+}
+
+// Read a reference to a BDD node.
+func (d *decoder) bdd(nodes []*BDD) *BDD {
+	if id := d.num(); id >= 0 && id < len(nodes) {
+		return nodes[id]
+	}
+	d.fail("invalid node reference")
+	return False
+}
+
+// Check that the whole record was read.
+func (d *decoder) end() {
+	if !d.binary && d.err == nil {
+		if strings.TrimSpace(d.line) != "" {
+			d.fail("unexpected data %q", d.line)
+		}
+		d.fresh = true
+	}
+}
+
+// Verify the checksum.
+func (d *decoder) close() error {
+	sum := d.crc.Sum32()
+	if n := d.num(); d.err == nil && uint32(n) != sum {
+		d.fail("checksum mismatch")
+	}
+	d.end()
+	return d.err
+}
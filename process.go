@@ -0,0 +1,199 @@
+package ctl
+
+import "fmt"
+
+// Process is a component of a model with local variables and transitions. The
+// transitions of processes are added to the model by composing them with
+// Interleave or Synchronize.
+type Process struct {
+	m     *Model
+	name  string
+	vars  []*Variable   // Local variables
+	trans []*transition // Local transitions
+}
+
+// A transition as it is passed to Model.Add.
+type transition struct {
+	condition  *BDD
+	constraint *BDD
+}
+
+// Module describes the variables and transitions of a process. Parameters can
+// be passed to a module using a closure, for example:
+//
+//	counter := func(limit uint) Module {
+//	  return func(p *Process) {
+//	    c := p.Int("c", limit)
+//	    p.Add(c.Lt(Int(limit)), c.Next().Eq(c.Add(Int(1))))
+//	  }
+//	}
+type Module func(p *Process)
+
+// Process creates a new process without variables and transitions.
+func (m *Model) Process(name string) *Process {
+	return &Process{m, name, nil, nil}
+}
+
+// Instance creates a new process using the given module.
+func (m *Model) Instance(name string, module Module) *Process {
+	p := m.Process(name)
+	module(p)
+	return p
+}
+
+// Name returns the name of this process.
+func (p *Process) Name() string {
+	return p.name
+}
+
+// Local variable name.
+func (p *Process) local(name string) string {
+	return fmt.Sprintf("%v.%v", p.name, name)
+}
+
+// Add the variables of a new local.
+func (p *Process) declare(vars ...*Variable) {
+	p.vars = append(p.vars, vars...)
+}
+
+// Bool creates a new local boolean variable.
+func (p *Process) Bool(name string) *BDD {
+	b := p.m.Bool(p.local(name))
+	p.declare(b.Var)
+	return b
+}
+
+// Int creates a new local integer variable that contains the given upperbound.
+func (p *Process) Int(name string, upb uint) *Integer {
+	i := p.m.Int(p.local(name), upb)
+	p.declare(i.vars...)
+	return i
+}
+
+// SignedInt creates a new local signed integer variable that contains the given
+// lowerbound and upperbound.
+func (p *Process) SignedInt(name string, lo int, hi int) *Integer {
+	i := p.m.SignedInt(p.local(name), lo, hi)
+	p.declare(i.vars...)
+	return i
+}
+
+// Enum creates a new local enum variable with a binary encoding.
+func (p *Process) Enum(name string, values ...string) *Enum {
+	e := p.m.Enum(p.local(name), values...)
+	p.declare(e.vars...)
+	return e
+}
+
+// Array creates a new local array of integer variables.
+func (p *Process) Array(name string, size int, elemUpb uint) *Array {
+	a := p.m.Array(p.local(name), size, elemUpb)
+	for _, elem := range a.elems {
+		p.declare(elem.vars...)
+	}
+	return a
+}
+
+// Add adds a new local transition. The constraint only has to describe the
+// local variables that change: local variables (and bits of local integers)
+// that the constraint does not depend on in the next step are kept unchanged,
+// so an integer should be assigned as a whole. When the processes are composed,
+// the local variables of other processes and the global variables that are not
+// mentioned are kept unchanged as well.
+func (p *Process) Add(condition *BDD, constraint *BDD) {
+	p.trans = append(p.trans, &transition{condition, frame(constraint, p.vars)})
+}
+
+// Unchanged returns a BDD that is true when no local variable changes in the
+// next step.
+func (p *Process) Unchanged() *BDD {
+	return unchanged(p.vars)
+}
+
+// Get a BDD that is true when none of the variables change in the next step.
+func unchanged(vars []*Variable) *BDD {
+	unchanged := True
+	for _, v := range vars {
+		unchanged = unchanged.And(Node(v.Next(), True, False).Eq(Node(v, True, False)))
+	}
+	return unchanged
+}
+
+// Keep the variables that the constraint does not depend on in the next step
+// unchanged.
+func frame(constraint *BDD, vars []*Variable) *BDD {
+	mentioned := make(map[*Variable]bool)
+	for _, v := range constraint.Support() {
+		mentioned[v] = true
+	}
+	for _, v := range vars {
+		if !mentioned[v.Next()] {
+			constraint = constraint.And(unchanged([]*Variable{v}))
+		}
+	}
+	return constraint
+}
+
+// Get the variables of the model that are not local to any of the processes.
+func (m *Model) globals(processes []*Process) []*Variable {
+	local := make(map[*Variable]bool)
+	for _, p := range processes {
+		for _, v := range p.vars {
+			local[v] = true
+		}
+	}
+	globals := make([]*Variable, 0)
+	for _, v := range m.vars {
+		if !local[v] {
+			globals = append(globals, v)
+		}
+	}
+	return globals
+}
+
+// Interleave adds the transitions of the given processes to the model such that
+// in each step one process takes a transition and all other processes keep
+// their local variables unchanged. The transitions are named after their
+// process. The first error returned by Model.Add is returned.
+func (m *Model) Interleave(processes ...*Process) (err error) {
+	defer recoverLimit(&err)
+	globals := m.globals(processes)
+	for _, p := range processes {
+		idle := True
+		for _, q := range processes {
+			if q != p {
+				idle = idle.And(q.Unchanged())
+			}
+		}
+		for _, t := range p.trans {
+			constraint := frame(t.constraint, globals).And(idle)
+			if e := m.AddRule(p.name, t.condition, constraint); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// Synchronize adds a transition to the model in which all given processes take
+// a transition at the same time. Processes that do not have a transition that
+// is enabled keep their local variables unchanged, and global variables that no
+// transition mentions (or that are mentioned but no process moves) are kept
+// unchanged. The transitions of different processes should not constrain the
+// same variables.
+func (m *Model) Synchronize(processes ...*Process) (err error) {
+	defer recoverLimit(&err)
+	step, stuck := True, True
+	for _, p := range processes {
+		moves := False
+		for _, t := range p.trans {
+			moves = moves.Or(t.condition.And(t.constraint))
+		}
+		enabled := m.existsNext(moves)
+		step = step.And(moves.Or(enabled.Neg().And(p.Unchanged())))
+		stuck = stuck.And(enabled.Neg())
+	}
+	globals := m.globals(processes)
+	step = frame(step, globals).And(stuck.Imply(unchanged(globals)))
+	return m.Add(True, step)
+}
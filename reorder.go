@@ -0,0 +1,253 @@
+package ctl
+
+import (
+	"runtime"
+	"sort"
+	"weak"
+)
+
+// Number of reorderings (see Reorder).
+var _reorderRuns = 0
+
+// Sifting stops moving a variable when the number of nodes grows by more than
+// this factor.
+const maxGrowth = 1.2
+
+// Reorder changes the variable ordering of all models to reduce the number of
+// nodes. Each variable is moved (together with its next twin) to the position
+// where the fewest nodes are needed, which is known as sifting. The nodes are
+// changed in place, so all BDDs keep their meaning. Since BDDs that are being
+// computed would become invalid, Reorder must not be called during BDD
+// operations in other goroutines. When the memory limit is exceeded during a
+// fixpoint computation, the variables are reordered automatically (see
+// SetMemoryLimit).
+func Reorder() {
+	CollectGarbage()
+	enabled := _weak
+	setWeak(true)
+	if r := newReorderer(); r != nil {
+		for _, blocks := range r.models {
+			r.sift(blocks)
+		}
+	}
+	_reorderRuns++
+	CollectGarbage()
+	setWeak(enabled)
+}
+
+// State of a reordering. Nodes cannot be removed (other BDDs may still refer to
+// them), but the live nodes are counted using the number of references from
+// other live nodes. Nodes that are not referenced by other nodes when the
+// reordering starts are referenced from outside of the engine.
+type reorderer struct {
+	nodes  map[*Variable][]*BDD // All nodes of each variable
+	refs   map[*BDD]int         // Number of references to each node
+	size   int                  // Number of live nodes
+	models [][]*Variable        // Normal variables of each model by sequence number
+}
+
+func newReorderer() *reorderer {
+	r := &reorderer{make(map[*Variable][]*BDD), make(map[*BDD]int), 0, nil}
+	valid := true
+	_weakLookup.Range(func(key, ref any) bool {
+		if p := ref.(weak.Pointer[BDD]).Value(); p != nil {
+			// Variables that are not created by a model cannot be moved.
+			valid = valid && p.Var.ordered() && p.Var.root != nil
+			r.nodes[p.Var] = append(r.nodes[p.Var], p)
+			r.refs[p] += 0
+			for _, q := range []*BDD{p.True, p.False} {
+				if q.Node() {
+					r.refs[q]++
+				}
+			}
+		}
+		return valid
+	})
+	if !valid {
+		return nil
+	}
+	for p, refs := range r.refs {
+		if refs == 0 {
+			r.refs[p] = 1
+		}
+	}
+	r.size = len(r.refs)
+
+	// Group the variables by model.
+	models := make(map[**Variable][]*Variable)
+	seen := make(map[*Variable]bool)
+	for v := range r.nodes {
+		if v = v.Norm(); !seen[v] {
+			seen[v] = true
+			models[v.root] = append(models[v.root], v)
+		}
+	}
+	for _, blocks := range models {
+		sort.Slice(blocks, func(i, j int) bool { return blocks[i].seq < blocks[j].seq })
+		r.models = append(r.models, blocks)
+	}
+	return r
+}
+
+// Add a reference to a node (and to its branches if it was dead).
+func (r *reorderer) ref(p *BDD) {
+	if !p.Node() {
+		return
+	}
+	if r.refs[p]++; r.refs[p] == 1 {
+		r.size++
+		r.ref(p.True)
+		r.ref(p.False)
+	}
+}
+
+// Remove a reference to a node (and from its branches if it is now dead).
+func (r *reorderer) deref(p *BDD) {
+	if !p.Node() {
+		return
+	}
+	if r.refs[p]--; r.refs[p] == 0 {
+		r.size--
+		r.deref(p.True)
+		r.deref(p.False)
+	}
+}
+
+// Get the unique node for v, t and f. A new node is not referenced yet.
+func (r *reorderer) node(v *Variable, t *BDD, f *BDD) *BDD {
+	if t == f {
+		return t
+	}
+	key := nodeKey{v, weak.Make(t), weak.Make(f)}
+	ref, in := _weakLookup.Load(key)
+	if in {
+		if p := ref.(weak.Pointer[BDD]).Value(); p != nil {
+			return p
+		}
+	}
+	p := &BDD{false, v, t, f}
+	_weakLookup.Store(key, weak.Make(p))
+	if !in {
+		if _nodeCount++; _nodeCount > _peakNodes {
+			_peakNodes = _nodeCount
+		}
+	}
+	r.nodes[v] = append(r.nodes[v], p)
+	return p
+}
+
+// Forget the dead nodes that are not referenced outside of the engine (the
+// others still have to be reordered).
+func (r *reorderer) prune() {
+	dead := make(map[*Variable][]weak.Pointer[BDD])
+	for v, nodes := range r.nodes {
+		live := nodes[:0]
+		for _, p := range nodes {
+			if r.refs[p] > 0 {
+				live = append(live, p)
+			} else {
+				dead[v] = append(dead[v], weak.Make(p))
+				delete(r.refs, p)
+			}
+		}
+		clear(nodes[len(live):])
+		r.nodes[v] = live
+	}
+	runtime.GC()
+	for v, nodes := range dead {
+		for _, p := range nodes {
+			if p := p.Value(); p != nil {
+				r.nodes[v] = append(r.nodes[v], p)
+			}
+		}
+	}
+}
+
+// Branches of p for both values of v.
+func cofactors(p *BDD, v *Variable) (*BDD, *BDD) {
+	if p.Var == v {
+		return p.True, p.False
+	}
+	return p, p
+}
+
+// Swap the variable x with the variable y that comes directly after it. The
+// nodes of x that depend on y are changed into nodes of y with the same
+// meaning.
+func (r *reorderer) swap(x *Variable, y *Variable) {
+	nodes := r.nodes[x]
+	r.nodes[x] = nil
+	for _, p := range nodes {
+		if p.True.Var != y && p.False.Var != y {
+			r.nodes[x] = append(r.nodes[x], p)
+			continue
+		}
+		f11, f10 := cofactors(p.True, y)
+		f01, f00 := cofactors(p.False, y)
+		t, f := r.node(x, f11, f01), r.node(x, f10, f00)
+		if r.refs[p] > 0 {
+			r.ref(t)
+			r.ref(f)
+			r.deref(p.True)
+			r.deref(p.False)
+		}
+		_weakLookup.Delete(nodeKey{x, weak.Make(p.True), weak.Make(p.False)})
+		p.Var, p.True, p.False = y, t, f
+		_weakLookup.Store(nodeKey{y, weak.Make(t), weak.Make(f)}, weak.Make(p))
+		r.nodes[y] = append(r.nodes[y], p)
+	}
+	x.seq, y.seq = y.seq, x.seq
+}
+
+// Swap the variable a (and its next twin) with the variable b that comes
+// directly after it: a a' b b' -> a b a' b' -> b a a' b' -> b a b' a' ->
+// b b' a a'.
+func (r *reorderer) swapBlocks(a *Variable, b *Variable) {
+	r.swap(a.Next(), b)
+	r.swap(a, b)
+	r.swap(a.Next(), b.Next())
+	r.swap(a, b.Next())
+}
+
+// Move each variable of a model to the position with the fewest live nodes,
+// starting with the variables that have the most nodes.
+func (r *reorderer) sift(blocks []*Variable) {
+	count := func(v *Variable) int {
+		return len(r.nodes[v]) + len(r.nodes[v.Next()])
+	}
+	vars := append([]*Variable(nil), blocks...)
+	sort.SliceStable(vars, func(i, j int) bool { return count(vars[i]) > count(vars[j]) })
+	for _, v := range vars {
+		pos := 0
+		for blocks[pos] != v {
+			pos++
+		}
+		best, bestPos := r.size, pos
+		move := func(down bool) {
+			i := pos - 1
+			if down {
+				i = pos
+			}
+			r.swapBlocks(blocks[i], blocks[i+1])
+			blocks[i], blocks[i+1] = blocks[i+1], blocks[i]
+			if down {
+				pos++
+			} else {
+				pos--
+			}
+			if r.size < best {
+				best, bestPos = r.size, pos
+			}
+		}
+		for pos < len(blocks)-1 && float64(r.size) <= maxGrowth*float64(best) {
+			move(true)
+		}
+		for pos > 0 && float64(r.size) <= maxGrowth*float64(best) || pos > bestPos {
+			move(false)
+		}
+		for pos < bestPos {
+			move(true)
+		}
+		r.prune()
+	}
+}
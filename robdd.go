@@ -44,6 +44,42 @@ func (p *BDD) Equals(q *BDD) bool {
 	return p.Value == q.Value
 }
 
+// Size returns the number of nodes in p (leaves are not counted).
+func (p *BDD) Size() int {
+	seen := make(map[*BDD]bool)
+	var visit func(q *BDD)
+	visit = func(q *BDD) {
+		if q.Node() && !seen[q] {
+			seen[q] = true
+			visit(q.True)
+			visit(q.False)
+		}
+	}
+	visit(p)
+	return len(seen)
+}
+
+// Support returns the variables that p depends on (in no particular order).
+func (p *BDD) Support() []*Variable {
+	seen := make(map[*BDD]bool)
+	found := make(map[*Variable]bool)
+	vars := make([]*Variable, 0)
+	var visit func(q *BDD)
+	visit = func(q *BDD) {
+		if q.Node() && !seen[q] {
+			seen[q] = true
+			if !found[q.Var] {
+				found[q.Var] = true
+				vars = append(vars, q.Var)
+			}
+			visit(q.True)
+			visit(q.False)
+		}
+	}
+	visit(p)
+	return vars
+}
+
 // Next returns a BDD with all next variable identifiers. By convention all
 // variable ID's are left-shifted 1 place. The same variable in the next state
 // is encoded by setting the first bit to 1.
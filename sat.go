@@ -0,0 +1,580 @@
+package ctl
+
+// Lit is a literal in a SAT problem. Variables are numbered from 1, a positive
+// literal is the variable number and a negative literal is its negation (as in
+// the DIMACS format).
+type Lit int
+
+// Neg returns the negation of this literal.
+func (l Lit) Neg() Lit {
+	return -l
+}
+
+// Variable index of a literal.
+func (l Lit) v() int {
+	if l < 0 {
+		return int(-l)
+	}
+	return int(l)
+}
+
+// Index of a literal in the watch lists.
+func (l Lit) index() int {
+	if l < 0 {
+		return 2*int(-l) + 1
+	}
+	return 2 * int(l)
+}
+
+// A clause in the solver. The first two literals are watched.
+type clause struct {
+	lits   []Lit
+	learnt bool
+}
+
+// Solver is a CDCL (conflict driven clause learning) SAT solver. Clauses can be
+// added between calls to Solve, and Solve accepts assumptions so that a single
+// solver can be used incrementally.
+type Solver struct {
+	ok       bool        // False if the clauses are unsatisfiable
+	assigns  []int8      // Value of each variable (0 is unassigned)
+	level    []int       // Decision level of each assigned variable
+	reason   []*clause   // Clause that implied each assigned variable
+	phase    []bool      // Last assigned value of each variable
+	activity []float64   // Activity of each variable (VSIDS)
+	seen     []bool      // Marks used during conflict analysis
+	watches  [][]*clause // Clauses watching each literal
+	clauses  []*clause   // All clauses
+	trail    []Lit       // Assigned literals in order
+	trailLim []int       // Start of each decision level in the trail
+	qhead    int         // Next literal in the trail to propagate
+	heap     []int       // Unassigned variables ordered by activity
+	heapPos  []int       // Position of each variable in the heap (or -1)
+	inc      float64     // Current activity increment
+	model    []bool      // Satisfying assignment of the last call to Solve
+	conflict []Lit       // Failed assumptions of the last call to Solve
+	released []int       // Released variables (see ReleaseVar)
+	free     []int       // Variables that can be reused by NewVar
+}
+
+// NewSolver creates a new SAT solver without variables.
+func NewSolver() *Solver {
+	s := &Solver{ok: true, inc: 1}
+	// Variable 0 is not used.
+	s.NewVar()
+	return s
+}
+
+// NewVar creates a new variable and returns its positive literal. Variables
+// that were released are reused.
+func (s *Solver) NewVar() Lit {
+	if n := len(s.free); n > 0 {
+		v := s.free[n-1]
+		s.free = s.free[:n-1]
+		s.phase[v], s.activity[v] = false, 0
+		if s.heapPos[v] < 0 {
+			s.heapInsert(v)
+		} else {
+			s.heapDown(s.heapPos[v])
+		}
+		return Lit(v)
+	}
+	v := len(s.assigns)
+	s.assigns = append(s.assigns, 0)
+	s.level = append(s.level, 0)
+	s.reason = append(s.reason, nil)
+	s.phase = append(s.phase, false)
+	s.activity = append(s.activity, 0)
+	s.seen = append(s.seen, false)
+	s.watches = append(s.watches, nil, nil)
+	s.heapPos = append(s.heapPos, -1)
+	if v > 0 {
+		s.heapInsert(v)
+	}
+	return Lit(v)
+}
+
+// NumVars returns the number of variables in the solver.
+func (s *Solver) NumVars() int {
+	return len(s.assigns) - 1
+}
+
+// Current value of a literal (1 true, -1 false, 0 unassigned).
+func (s *Solver) value(l Lit) int8 {
+	if l < 0 {
+		return -s.assigns[-l]
+	}
+	return s.assigns[l]
+}
+
+// AddClause adds a clause (disjunction of literals). It returns false if the
+// clauses are now known to be unsatisfiable.
+func (s *Solver) AddClause(lits ...Lit) bool {
+	if !s.ok {
+		return false
+	}
+	s.cancelUntil(0)
+
+	// Remove false literals and duplicates, and skip satisfied clauses.
+	c := make([]Lit, 0, len(lits))
+	for _, l := range lits {
+		switch {
+		case s.value(l) == 1:
+			return true
+		case s.value(l) == -1:
+			continue
+		}
+		duplicate := false
+		for _, k := range c {
+			if k == l {
+				duplicate = true
+			} else if k == l.Neg() {
+				return true
+			}
+		}
+		if !duplicate {
+			c = append(c, l)
+		}
+	}
+
+	switch len(c) {
+	case 0:
+		s.ok = false
+	case 1:
+		s.assign(c[0], nil)
+		s.ok = s.propagate() == nil
+	default:
+		s.attach(&clause{c, false})
+	}
+	return s.ok
+}
+
+// Add a clause to the watch lists.
+func (s *Solver) attach(c *clause) {
+	s.clauses = append(s.clauses, c)
+	s.watch(c)
+}
+
+func (s *Solver) watch(c *clause) {
+	s.watches[c.lits[0].Neg().index()] = append(s.watches[c.lits[0].Neg().index()], c)
+	s.watches[c.lits[1].Neg().index()] = append(s.watches[c.lits[1].Neg().index()], c)
+}
+
+// ReleaseVar permanently makes the literal l false (so l should not be implied
+// by the clauses, as for an activation literal of temporary clauses). Its
+// variable is reused by NewVar after the next call to Simplify.
+func (s *Solver) ReleaseVar(l Lit) {
+	if s.AddClause(l.Neg()) {
+		s.released = append(s.released, l.v())
+	}
+}
+
+// Simplify removes the clauses that are satisfied without any assumptions and
+// the false literals from all other clauses, after which the released
+// variables no longer occur in any clause and can be reused. It returns false
+// if the clauses are unsatisfiable.
+func (s *Solver) Simplify() bool {
+	if !s.ok {
+		return false
+	}
+	s.cancelUntil(0)
+	if s.propagate() != nil {
+		s.ok = false
+		return false
+	}
+	for i := range s.watches {
+		s.watches[i] = nil
+	}
+	clauses := s.clauses[:0]
+	for _, c := range s.clauses {
+		lits := make([]Lit, 0, len(c.lits))
+		satisfied := false
+		for _, l := range c.lits {
+			switch s.value(l) {
+			case 1:
+				satisfied = true
+			case 0:
+				lits = append(lits, l)
+			}
+		}
+		// Since all assignments are propagated, at least two literals remain.
+		if !satisfied {
+			c.lits = lits
+			clauses = append(clauses, c)
+			s.watch(c)
+		}
+	}
+	s.clauses = clauses
+
+	// Unassign the released variables (the other top level assignments no
+	// longer need a reason).
+	released := make(map[int]bool, len(s.released))
+	for _, v := range s.released {
+		released[v] = true
+		s.assigns[v] = 0
+		s.free = append(s.free, v)
+	}
+	s.released = nil
+	trail := s.trail[:0]
+	for _, l := range s.trail {
+		s.reason[l.v()] = nil
+		if !released[l.v()] {
+			trail = append(trail, l)
+		}
+	}
+	s.trail = trail
+	s.qhead = len(trail)
+	return true
+}
+
+// Assign a literal to true.
+func (s *Solver) assign(l Lit, reason *clause) {
+	v := l.v()
+	s.assigns[v] = 1
+	if l < 0 {
+		s.assigns[v] = -1
+	}
+	s.level[v] = len(s.trailLim)
+	s.reason[v] = reason
+	s.trail = append(s.trail, l)
+}
+
+// Propagate all assigned literals. Returns a conflicting clause (if any).
+func (s *Solver) propagate() *clause {
+	for s.qhead < len(s.trail) {
+		p := s.trail[s.qhead]
+		s.qhead++
+		ws := s.watches[p.index()]
+		kept := ws[:0]
+		var conflict *clause
+		for i, c := range ws {
+			if conflict != nil {
+				kept = append(kept, ws[i:]...)
+				break
+			}
+			// Make sure the false literal is at index 1.
+			if c.lits[0] == p.Neg() {
+				c.lits[0], c.lits[1] = c.lits[1], c.lits[0]
+			}
+			if s.value(c.lits[0]) == 1 {
+				kept = append(kept, c)
+				continue
+			}
+			// Look for a new literal to watch.
+			moved := false
+			for k := 2; k < len(c.lits); k++ {
+				if s.value(c.lits[k]) != -1 {
+					c.lits[1], c.lits[k] = c.lits[k], c.lits[1]
+					idx := c.lits[1].Neg().index()
+					s.watches[idx] = append(s.watches[idx], c)
+					moved = true
+					break
+				}
+			}
+			if moved {
+				continue
+			}
+			// The clause is unit or conflicting.
+			kept = append(kept, c)
+			if s.value(c.lits[0]) == -1 {
+				conflict = c
+			} else {
+				s.assign(c.lits[0], c)
+			}
+		}
+		s.watches[p.index()] = kept
+		if conflict != nil {
+			return conflict
+		}
+	}
+	return nil
+}
+
+// Analyze a conflict and return a learnt clause (first UIP) and the level to
+// backtrack to.
+func (s *Solver) analyze(conflict *clause) ([]Lit, int) {
+	learnt := []Lit{0} // The first literal is the asserting literal.
+	counter := 0
+	var p Lit
+	i := len(s.trail) - 1
+	for {
+		for _, q := range conflict.lits {
+			if q == p {
+				continue
+			}
+			v := q.v()
+			if !s.seen[v] && s.level[v] > 0 {
+				s.seen[v] = true
+				s.bump(v)
+				if s.level[v] == len(s.trailLim) {
+					counter++
+				} else {
+					learnt = append(learnt, q)
+				}
+			}
+		}
+		// Select the next literal on the trail to look at.
+		for !s.seen[s.trail[i].v()] {
+			i--
+		}
+		p = s.trail[i]
+		conflict = s.reason[p.v()]
+		s.seen[p.v()] = false
+		counter--
+		i--
+		if counter == 0 {
+			break
+		}
+	}
+	learnt[0] = p.Neg()
+
+	// Find the backtrack level (and put that literal at index 1).
+	level := 0
+	for k := 1; k < len(learnt); k++ {
+		s.seen[learnt[k].v()] = false
+		if s.level[learnt[k].v()] > level {
+			level = s.level[learnt[k].v()]
+			learnt[1], learnt[k] = learnt[k], learnt[1]
+		}
+	}
+	return learnt, level
+}
+
+// Compute the assumptions that caused assumption p to be false.
+func (s *Solver) analyzeFinal(p Lit) []Lit {
+	conflict := []Lit{p}
+	if len(s.trailLim) == 0 {
+		return conflict
+	}
+	s.seen[p.v()] = true
+	for i := len(s.trail) - 1; i >= s.trailLim[0]; i-- {
+		v := s.trail[i].v()
+		if !s.seen[v] {
+			continue
+		}
+		if s.reason[v] == nil {
+			// Decisions below the assumption levels are assumptions.
+			conflict = append(conflict, s.trail[i])
+		} else {
+			for _, q := range s.reason[v].lits[1:] {
+				if s.level[q.v()] > 0 {
+					s.seen[q.v()] = true
+				}
+			}
+		}
+		s.seen[v] = false
+	}
+	s.seen[p.v()] = false
+	return conflict
+}
+
+// Undo all assignments above the given decision level.
+func (s *Solver) cancelUntil(level int) {
+	if len(s.trailLim) <= level {
+		return
+	}
+	for i := len(s.trail) - 1; i >= s.trailLim[level]; i-- {
+		v := s.trail[i].v()
+		s.phase[v] = s.assigns[v] == 1
+		s.assigns[v] = 0
+		s.reason[v] = nil
+		if s.heapPos[v] < 0 {
+			s.heapInsert(v)
+		}
+	}
+	s.trail = s.trail[:s.trailLim[level]]
+	s.trailLim = s.trailLim[:level]
+	s.qhead = len(s.trail)
+}
+
+// Solve determines if the clauses are satisfiable given that all assumptions
+// are true. After a successful call the assignment is available using Value,
+// otherwise the failed assumptions are available using Conflict.
+func (s *Solver) Solve(assumptions ...Lit) bool {
+	s.model = nil
+	s.conflict = nil
+	if !s.ok {
+		return false
+	}
+	defer s.cancelUntil(0)
+
+	for restarts := 0; ; restarts++ {
+		result, done := s.search(100*luby(restarts), assumptions)
+		if done {
+			return result
+		}
+	}
+}
+
+// Search for a satisfying assignment until the given number of conflicts.
+func (s *Solver) search(budget int, assumptions []Lit) (bool, bool) {
+	conflicts := 0
+	for {
+		if conflict := s.propagate(); conflict != nil {
+			conflicts++
+			if len(s.trailLim) == 0 {
+				s.ok = false
+				return false, true
+			}
+			learnt, level := s.analyze(conflict)
+			s.cancelUntil(level)
+			if len(learnt) == 1 {
+				s.assign(learnt[0], nil)
+			} else {
+				c := &clause{learnt, true}
+				s.attach(c)
+				s.assign(learnt[0], c)
+			}
+			s.inc *= 1.05
+			continue
+		}
+
+		// Restart after too many conflicts.
+		if conflicts >= budget {
+			s.cancelUntil(0)
+			return false, false
+		}
+
+		// Assign the next assumption, or pick a new decision variable.
+		var next Lit
+		for len(s.trailLim) < len(assumptions) {
+			p := assumptions[len(s.trailLim)]
+			if s.value(p) == 1 {
+				// Create a dummy decision level.
+				s.trailLim = append(s.trailLim, len(s.trail))
+			} else if s.value(p) == -1 {
+				s.conflict = s.analyzeFinal(p)
+				return false, true
+			} else {
+				next = p
+				break
+			}
+		}
+		if next == 0 {
+			v := s.pickBranchVar()
+			if v == 0 {
+				// All variables are assigned.
+				s.model = make([]bool, len(s.assigns))
+				for i := range s.assigns {
+					s.model[i] = s.assigns[i] == 1
+				}
+				return true, true
+			}
+			next = Lit(v)
+			if !s.phase[v] {
+				next = next.Neg()
+			}
+		}
+		s.trailLim = append(s.trailLim, len(s.trail))
+		s.assign(next, nil)
+	}
+}
+
+// Value returns the value of a literal in the last satisfying assignment.
+func (s *Solver) Value(l Lit) bool {
+	if l < 0 {
+		return !s.model[-l]
+	}
+	return s.model[l]
+}
+
+// Conflict returns the subset of the assumptions of the last call to Solve that
+// caused it to be unsatisfiable.
+func (s *Solver) Conflict() []Lit {
+	return s.conflict
+}
+
+// Increase the activity of a variable.
+func (s *Solver) bump(v int) {
+	s.activity[v] += s.inc
+	if s.activity[v] > 1e100 {
+		for i := range s.activity {
+			s.activity[i] *= 1e-100
+		}
+		s.inc *= 1e-100
+	}
+	if s.heapPos[v] >= 0 {
+		s.heapUp(s.heapPos[v])
+	}
+}
+
+// Pick the unassigned variable with the highest activity (or 0).
+func (s *Solver) pickBranchVar() int {
+	for len(s.heap) > 0 {
+		v := s.heapPop()
+		if s.assigns[v] == 0 {
+			return v
+		}
+	}
+	return 0
+}
+
+func (s *Solver) heapInsert(v int) {
+	s.heapPos[v] = len(s.heap)
+	s.heap = append(s.heap, v)
+	s.heapUp(len(s.heap) - 1)
+}
+
+func (s *Solver) heapPop() int {
+	v := s.heap[0]
+	last := s.heap[len(s.heap)-1]
+	s.heap = s.heap[:len(s.heap)-1]
+	s.heapPos[v] = -1
+	if len(s.heap) > 0 {
+		s.heap[0] = last
+		s.heapPos[last] = 0
+		s.heapDown(0)
+	}
+	return v
+}
+
+func (s *Solver) heapUp(i int) {
+	v := s.heap[i]
+	for i > 0 {
+		parent := (i - 1) / 2
+		if s.activity[s.heap[parent]] >= s.activity[v] {
+			break
+		}
+		s.heap[i] = s.heap[parent]
+		s.heapPos[s.heap[i]] = i
+		i = parent
+	}
+	s.heap[i] = v
+	s.heapPos[v] = i
+}
+
+func (s *Solver) heapDown(i int) {
+	v := s.heap[i]
+	for {
+		child := 2*i + 1
+		if child >= len(s.heap) {
+			break
+		}
+		if child+1 < len(s.heap) && s.activity[s.heap[child+1]] > s.activity[s.heap[child]] {
+			child++
+		}
+		if s.activity[s.heap[child]] <= s.activity[v] {
+			break
+		}
+		s.heap[i] = s.heap[child]
+		s.heapPos[s.heap[i]] = i
+		i = child
+	}
+	s.heap[i] = v
+	s.heapPos[v] = i
+}
+
+// Compute the i-th element of the Luby sequence (1, 1, 2, 1, 1, 2, 4, ...).
+func luby(i int) int {
+	size, seq := 1, 0
+	for size < i+1 {
+		seq++
+		size = 2*size + 1
+	}
+	for size-1 != i {
+		size = (size - 1) / 2
+		seq--
+		i = i % size
+	}
+	return 1 << seq
+}
@@ -0,0 +1,181 @@
+package ctl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Engine counters (see Stats).
+var _peakNodes, _gcRuns = 0, 0
+var _cacheHits, _cacheMisses [16]int
+
+// Names of the Apply operators (by truth table).
+var opNames = map[uint]string{
+	0b0001: "and",
+	0b0111: "or",
+	0b1101: "imply",
+	0b1001: "eq",
+	0b0110: "xor",
+}
+
+func opName(op uint) string {
+	if name, in := opNames[op]; in {
+		return name
+	}
+	return fmt.Sprintf("op%04b", op)
+}
+
+// Stats contains statistics of the BDD engine and of the fixpoint computations
+// of a model.
+type Stats struct {
+	Nodes       int                   // Nodes in the lookup table
+	PeakNodes   int                   // Largest number of nodes in the lookup table
+	CacheSize   int                   // Entries in the apply cache
+	Cache       map[string]CacheStats // Apply cache statistics per operator
+	GCRuns      int                   // Number of garbage collections
+	ReorderRuns int                   // Number of reorderings (see Reorder)
+	Fixpoints   []FixpointStats       // Last fixpoint computations of the model
+}
+
+// CacheStats contains the apply cache statistics of an operator.
+type CacheStats struct {
+	Hits   int
+	Misses int
+}
+
+// FixpointStats contains the time spent on the iterations of a fixpoint
+// computation (such as EU or AG) for a subformula.
+type FixpointStats struct {
+	Operator   string
+	Label      string // Label of the subformula (see WithLabel and Model.Stats)
+	Iterations []time.Duration
+}
+
+// Maximum number of fixpoint computations that are kept per model.
+const maxFixpoints = 1000
+
+// Total returns the total time spent on the fixpoint computation.
+func (f FixpointStats) Total() time.Duration {
+	total := time.Duration(0)
+	for _, d := range f.Iterations {
+		total += d
+	}
+	return total
+}
+
+// Stats returns the current statistics of the BDD engine (which is shared by
+// all models) and of the fixpoint computations of this model. A computation
+// without a label (see WithLabel) is labelled with its operator and operands,
+// such as "EU(true, a b: 12 nodes)".
+func (m *Model) Stats() Stats {
+	cache := make(map[string]CacheStats)
+	for op := range _cacheHits {
+		if _cacheHits[op] > 0 || _cacheMisses[op] > 0 {
+			cache[opName(uint(op))] = CacheStats{_cacheHits[op], _cacheMisses[op]}
+		}
+	}
+	fixpoints := make([]FixpointStats, len(m.fixpoints))
+	for i, f := range m.fixpoints {
+		fixpoints[i] = *f
+	}
+	return Stats{_nodeCount, _peakNodes, _applyCount, cache, _gcRuns, _reorderRuns, fixpoints}
+}
+
+// ResetStats clears the fixpoint statistics of this model and the counters of
+// the BDD engine (which are shared by all models). The peak number of nodes is
+// reset to the current number of nodes.
+func (m *Model) ResetStats() {
+	m.fixpoints = nil
+	_peakNodes, _gcRuns, _reorderRuns = _nodeCount, 0, 0
+	_cacheHits, _cacheMisses = [16]int{}, [16]int{}
+}
+
+// Start recording the iterations of a fixpoint computation. Only the last
+// maxFixpoints computations are kept.
+func (m *Model) record(ctx context.Context, operator string, operands []*BDD) *FixpointStats {
+	label, in := ctx.Value(labelKey{}).(string)
+	if !in {
+		descriptions := make([]string, len(operands))
+		for i, p := range operands {
+			descriptions[i] = m.describe(p)
+		}
+		label = fmt.Sprintf("%v(%v)", operator, strings.Join(descriptions, ", "))
+	}
+	stats := &FixpointStats{operator, label, nil}
+	if len(m.fixpoints) == maxFixpoints {
+		m.fixpoints = append(m.fixpoints[:0], m.fixpoints[1:]...)
+	}
+	m.fixpoints = append(m.fixpoints, stats)
+	return stats
+}
+
+// Describe a set of states by the names of the variables it depends on and its
+// number of nodes.
+func (m *Model) describe(p *BDD) string {
+	if !p.Node() {
+		return fmt.Sprint(p.Value)
+	}
+	names := make(map[*Variable]string)
+	for _, i := range m.ints {
+		for _, v := range i.vars {
+			names[v] = i.Name()
+		}
+	}
+	for _, e := range m.enums {
+		for _, v := range e.vars {
+			names[v] = e.Name()
+		}
+	}
+	seen := make(map[string]bool)
+	vars := make([]string, 0)
+	for _, v := range p.Support() {
+		name, in := names[v.Norm()]
+		if !in {
+			name = v.Norm().Name
+		}
+		if v.next {
+			name = fmt.Sprintf("next(%v)", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			vars = append(vars, name)
+		}
+	}
+	return fmt.Sprintf("%v: %v nodes", strings.Join(vars, " "), p.Size())
+}
+
+// String formats the statistics as a report.
+func (s Stats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "nodes: %v (peak %v)\n", s.Nodes, s.PeakNodes)
+	fmt.Fprintf(&b, "apply cache: %v entries\n", s.CacheSize)
+	ops := make([]string, 0, len(s.Cache))
+	for op := range s.Cache {
+		ops = append(ops, op)
+	}
+	sort.Sort(ByStringLt(ops))
+	for _, op := range ops {
+		c := s.Cache[op]
+		ratio := 0.0
+		if c.Hits+c.Misses > 0 {
+			ratio = 100 * float64(c.Hits) / float64(c.Hits+c.Misses)
+		}
+		fmt.Fprintf(&b, "  %-6v %10v hits %10v misses (%.1f%%)\n", op, c.Hits, c.Misses, ratio)
+	}
+	fmt.Fprintf(&b, "garbage collections: %v\n", s.GCRuns)
+	fmt.Fprintf(&b, "reorderings: %v\n", s.ReorderRuns)
+	for i, f := range s.Fixpoints {
+		name := f.Label
+		if !strings.HasPrefix(f.Label, f.Operator+"(") {
+			name = fmt.Sprintf("%v (%v)", f.Label, f.Operator)
+		}
+		fmt.Fprintf(&b, "%v. %v: %v iterations in %v\n", i+1, name, len(f.Iterations), f.Total())
+		for j, d := range f.Iterations {
+			fmt.Fprintf(&b, "  %v: %v\n", j+1, d)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,218 @@
+package ctl
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Trace is a path of states together with the rules that were taken.
+type Trace struct {
+	m      *Model
+	states []*State
+	rules  []string // Rule that leads to each state ("" for the first state)
+}
+
+// Step is a state in a trace.
+type Step struct {
+	Index int    // Index of the step (the initial state has index 0)
+	Rule  string // Name of the rule that leads to this state
+	State *State
+}
+
+// Trace creates a trace from a path of states (for example a result of
+// GenerateExample or BMC). The name of the rule that leads to each state is
+// determined from the transitions of the model. If several rules allow a step,
+// the first one is used, and if none does the rule is empty.
+func (m *Model) Trace(states []*State) *Trace {
+	rules := make([]string, len(states))
+	for i := 1; i < len(states); i++ {
+		step := m.stateBDD(states[i-1]).And(m.stateBDD(states[i]).Next())
+		for r, rule := range m.rules {
+			if step.Intersects(rule) {
+				rules[i] = m.names[r]
+				break
+			}
+		}
+	}
+	return &Trace{m, states, rules}
+}
+
+// Get the states that match the given state (auxiliary variables are free).
+func (m *Model) stateBDD(s *State) *BDD {
+	p := True
+	for _, v := range m.vars {
+		if b, in := s.bools[v.Name]; in {
+			if b {
+				p = p.And(Node(v, True, False))
+			} else {
+				p = p.And(Node(v, False, True))
+			}
+		}
+	}
+	for _, i := range m.ints {
+		if value, in := s.ints[i.Name()]; in {
+			if i.IsSigned() {
+				p = p.And(i.Eq(Signed(value)))
+			} else {
+				p = p.And(i.Eq(Int(uint(value))))
+			}
+		}
+	}
+	for _, e := range m.enums {
+		if value, in := s.enums[e.Name()]; in && value != "?" {
+			p = p.And(e.Is(value))
+		}
+	}
+	return p
+}
+
+// Len returns the number of states in the trace.
+func (t *Trace) Len() int {
+	return len(t.states)
+}
+
+// Step returns the i-th step of the trace.
+func (t *Trace) Step(i int) Step {
+	return Step{i, t.rules[i], t.states[i]}
+}
+
+// Steps returns all steps of the trace.
+func (t *Trace) Steps() []Step {
+	steps := make([]Step, len(t.states))
+	for i := range steps {
+		steps[i] = t.Step(i)
+	}
+	return steps
+}
+
+// States returns the states of the trace.
+func (t *Trace) States() []*State {
+	return t.states
+}
+
+// Get the column names of a table (the variable names of the first state).
+func (t *Trace) names() []string {
+	if len(t.states) == 0 {
+		return nil
+	}
+	return t.states[0].Names()
+}
+
+// MarshalJSON encodes the trace as a list of steps with the values of all
+// variables.
+func (t *Trace) MarshalJSON() ([]byte, error) {
+	type jsonStep struct {
+		Step   int                    `json:"step"`
+		Rule   string                 `json:"rule,omitempty"`
+		Values map[string]interface{} `json:"values"`
+	}
+	steps := make([]jsonStep, len(t.states))
+	for i, s := range t.states {
+		steps[i] = jsonStep{i, t.rules[i], s.values()}
+	}
+	return json.Marshal(steps)
+}
+
+// WriteJSON writes the trace as JSON (see MarshalJSON).
+func (t *Trace) WriteJSON(w io.Writer) error {
+	data, err := t.MarshalJSON()
+	if err == nil {
+		_, err = w.Write(append(data, '\n'))
+	}
+	return err
+}
+
+// WriteCSV writes the trace as CSV with a column for the step, the rule and
+// each variable.
+func (t *Trace) WriteCSV(w io.Writer) error {
+	names := t.names()
+	out := csv.NewWriter(w)
+	out.Write(append([]string{"step", "rule"}, names...))
+	for i, s := range t.states {
+		row := []string{fmt.Sprint(i), t.rules[i]}
+		for _, name := range names {
+			row = append(row, s.Value(name))
+		}
+		out.Write(row)
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteMarkdown writes the trace as a Markdown table with a row for each step.
+func (t *Trace) WriteMarkdown(w io.Writer) error {
+	names := t.names()
+	row := func(cells []string) string {
+		for i, cell := range cells {
+			cells[i] = strings.ReplaceAll(cell, "|", "\\|")
+		}
+		return "| " + strings.Join(cells, " | ") + " |\n"
+	}
+	var b strings.Builder
+	b.WriteString(row(append([]string{"step", "rule"}, names...)))
+	b.WriteString(strings.Repeat("| --- ", len(names)+2) + "|\n")
+	for i, s := range t.states {
+		cells := []string{fmt.Sprint(i), t.rules[i]}
+		for _, name := range names {
+			cells = append(cells, s.Value(name))
+		}
+		b.WriteString(row(cells))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteChanges writes the trace as a list of assignments in which the first
+// state is complete, and each next state only contains the variables that
+// changed (like the traces of NuSMV). Variables that any of the given
+// properties depend on are marked with an asterisk.
+func (t *Trace) WriteChanges(w io.Writer, properties ...*BDD) error {
+	// Find the names of the variables (and integers and enums) to mark.
+	owner := make(map[*Variable]string)
+	for _, i := range t.m.ints {
+		for _, v := range i.vars {
+			owner[v] = i.Name()
+		}
+	}
+	for _, e := range t.m.enums {
+		for _, v := range e.vars {
+			owner[v] = e.Name()
+		}
+	}
+	marked := make(map[string]bool)
+	for _, p := range properties {
+		for _, v := range p.Support() {
+			if name, in := owner[v.Norm()]; in {
+				marked[name] = true
+			} else {
+				marked[v.Norm().Name] = true
+			}
+		}
+	}
+
+	var b strings.Builder
+	names := t.names()
+	for i, s := range t.states {
+		if i == 0 {
+			b.WriteString("-> State 0 <-\n")
+		} else {
+			fmt.Fprintf(&b, "-> State %v (%v) <-\n", i, t.rules[i])
+		}
+		for _, name := range names {
+			value := s.Value(name)
+			if i > 0 && value == t.states[i-1].Value(name) {
+				continue
+			}
+			mark := " "
+			if marked[name] {
+				mark = "*"
+			}
+			fmt.Fprintf(&b, "%v %v = %v\n", mark, name, value)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
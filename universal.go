@@ -0,0 +1,107 @@
+package ctl
+
+import "context"
+
+// DeadlockPolicy determines how the universal operators treat deadlock states
+// (states without successors).
+type DeadlockPolicy int
+
+const (
+	// FinitePaths treats a deadlock state as the end of a finite path. AX holds
+	// in such a state for any goal (there is no next state that violates it),
+	// and AF only holds if the goal is reached before the path ends.
+	FinitePaths DeadlockPolicy = iota
+	// SelfLoops treats a deadlock state as if it transitions to itself. AX holds
+	// in such a state if the goal holds in the state itself.
+	SelfLoops
+	// FailOnDeadlock makes the universal operators return a DeadlockError if
+	// the model contains deadlock states that can affect the result: states in
+	// which the result depends on the successors (the start states of AX, the
+	// states in which the step condition of AU holds but the goal does not,
+	// and the states in which the condition of AR or ER holds but the release
+	// does not). Deadlock states elsewhere (for example unreachable states that
+	// violate the condition) are ignored.
+	FailOnDeadlock
+)
+
+// DeadlockError reports the deadlock states of a model when universal
+// operators are not allowed to handle them.
+type DeadlockError struct {
+	States *BDD // Deadlock states that can affect the result
+}
+
+func (e *DeadlockError) Error() string {
+	return "model contains deadlock states"
+}
+
+// SetDeadlockPolicy sets how the universal operators treat deadlock states. By
+// default deadlock states end a finite path. Note that EX, EU and EG only
+// consider infinite paths and are not affected by this policy.
+func (m *Model) SetDeadlockPolicy(policy DeadlockPolicy) {
+	m.deadlockPolicy = policy
+}
+
+// Get the deadlock states, or an error if they are not allowed in the states
+// in which the result depends on the successors.
+func (m *Model) checkDeadlocks(scope *BDD) (*BDD, error) {
+	dead := m.Deadlocks()
+	if m.deadlockPolicy == FailOnDeadlock && dead.Intersects(scope) {
+		return nil, &DeadlockError{dead.And(scope)}
+	}
+	return dead, nil
+}
+
+// AX returns the states in start for which all successors are in goal.
+func (m *Model) AX(start *BDD, goal *BDD) (_ *BDD, err error) {
+	defer recoverLimit(&err)
+	dead, err := m.checkDeadlocks(start)
+	if err != nil {
+		return nil, err
+	}
+	return m.ax(dead, start, goal), nil
+}
+
+func (m *Model) ax(dead *BDD, start *BDD, goal *BDD) *BDD {
+	// There is no successor outside of goal.
+	states := m.domain.And(start).And(m.EX(True, m.domain.And(goal.Neg())).Neg())
+	if m.deadlockPolicy == SelfLoops {
+		states = states.And(dead.Imply(goal))
+	}
+	return states
+}
+
+// AU returns all states for which all paths reach goal such that a given
+// condition holds for all steps before. The states for which this is the case
+// in at most n steps are returned in the n-th index.
+func (m *Model) AU(step *BDD, goal *BDD) ([]*BDD, error) {
+	return m.AUContext(context.Background(), step, goal)
+}
+
+// AF returns all states for which all paths reach goal. The states for which
+// this is the case in at most n steps are returned in the n-th index.
+func (m *Model) AF(goal *BDD) ([]*BDD, error) {
+	return m.AFContext(context.Background(), goal)
+}
+
+// AG returns states for which the condition holds globally on all paths. The
+// states for which the condition holds for at least n steps on all paths are
+// returned in the n-th index (the last set is the result).
+func (m *Model) AG(condition *BDD) ([]*BDD, error) {
+	return m.AGContext(context.Background(), condition)
+}
+
+// AR returns states for which on all paths the condition holds until and
+// including the step in which release holds (or forever). The states for which
+// this is the case for at least n steps are returned in the n-th index (the
+// last set is the result).
+func (m *Model) AR(release *BDD, condition *BDD) ([]*BDD, error) {
+	return m.ARContext(context.Background(), release, condition)
+}
+
+// ER returns states for which there exists a path on which the condition holds
+// until and including the step in which release holds (or forever, or until
+// the path ends in a deadlock state). The states for which this is the case for
+// at least n steps are returned in the n-th index (the last set is the result).
+func (m *Model) ER(release *BDD, condition *BDD) ([]*BDD, error) {
+	return m.ERContext(context.Background(), release, condition)
+}
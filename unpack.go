@@ -9,12 +9,14 @@ import (
 // State is a high level description of a variable assignment
 type State struct {
 	bools map[string]bool
-	ints  map[string]uint
+	ints  map[string]int
+	enums map[string]string
 }
 
 // Equals check if state s has the same assingment as state t.
 func (s *State) Equals(t *State) bool {
-	if len(s.bools) != len(t.bools) || len(s.ints) != len(t.ints) {
+	if len(s.bools) != len(t.bools) || len(s.ints) != len(t.ints) ||
+		len(s.enums) != len(t.enums) {
 		return false
 	}
 	for k, v := range s.bools {
@@ -27,12 +29,17 @@ func (s *State) Equals(t *State) bool {
 			return false
 		}
 	}
+	for k, v := range s.enums {
+		if w, in := t.enums[k]; !in || v != w {
+			return false
+		}
+	}
 	return true
 }
 
 // Less computes if the s has lower values than t.
 func (s *State) Less(t *State) bool {
-	// First consider booleans, then integers.
+	// First consider booleans, then integers, then enums.
 	if len(s.bools) != len(t.bools) {
 		return len(s.bools) < len(t.bools)
 	}
@@ -60,6 +67,20 @@ func (s *State) Less(t *State) bool {
 			return in && s.ints[name] < yv
 		}
 	}
+	// Consider enums.
+	if len(s.enums) != len(t.enums) {
+		return len(s.enums) < len(t.enums)
+	}
+	names = make([]string, 0, len(s.enums))
+	for name := range s.enums {
+		names = append(names, name)
+	}
+	sort.Sort(ByStringLt(names))
+	for _, name := range names {
+		if yv, in := t.enums[name]; !in || yv != s.enums[name] {
+			return in && s.enums[name] < yv
+		}
+	}
 	// All equal.
 	return false
 }
@@ -136,23 +157,23 @@ func expandStates(vars []*Variable, aux bool, states []map[*Variable]bool) []map
 	return expandStates(vars[1:], aux, result)
 }
 
-// Process one state (expand names and compute integer values).
+// Process one state (expand names and compute integer and enum values).
 // Auxiliary values are discarded unless aux is set.
 func processState(m *Model, state map[*Variable]bool, aux bool) *State {
 	bools := make(map[string]bool)
-	ints := make(map[string]uint, len(m.ints))
+	ints := make(map[string]int, len(m.ints))
 
 	// Extract integer values.
 	for _, i := range m.ints {
-		if !aux && i.Aux() {
-			continue
-		}
-
-		// Compute value
-		value := uint(0)
-		for n, v := range i.bits {
+		// Compute value (the sign bit of a signed integer is negative).
+		value := 0
+		for n, v := range i.vars {
 			if state[v] {
-				value += 1 << n
+				if i.signed && n == len(i.vars)-1 {
+					value -= 1 << n
+				} else {
+					value += 1 << n
+				}
 			}
 			delete(state, v)
 		}
@@ -161,6 +182,12 @@ func processState(m *Model, state map[*Variable]bool, aux bool) *State {
 		ints[i.Name()] = value
 	}
 
+	// Extract enum values.
+	enums := make(map[string]string, len(m.enums))
+	for _, e := range m.enums {
+		enums[e.Name()] = e.decode(state)
+	}
+
 	// Extract boolean values.
 	for v, b := range state {
 		if aux || !v.aux {
@@ -168,26 +195,11 @@ func processState(m *Model, state map[*Variable]bool, aux bool) *State {
 		}
 	}
 
-	return &State{bools, ints}
-}
-
-// Process a list of states.
-//
-// Note: For reasons I do not yet understand auxiliary variables are sometimes
-// restricted in unexpected ways such as in the following example. To get a
-// clean list of states I remove any duplicate results here.
-//
-//  a | add(a,5) | add(a,a)
-// ---|----------|----------
-// 44 | 0        | 88
-// 44 | 1        | 88
-// 44 | 3        | 88
-// 44 | 5        | 88
-// 44 | 9        | 88
-// 44 | 17       | 88
-// 44 | 49       | 0
-// 44 | 113      | 88
-// 44 | 177      | 88
+	return &State{bools, ints, enums}
+}
+
+// Process a list of states. States that only differ in discarded auxiliary
+// variables result in duplicates, so any duplicate results are removed here.
 func processStates(m *Model, states []map[*Variable]bool, aux bool) States {
 	result := make(States, 0, len(states))
 	for _, state := range states {
@@ -209,35 +221,91 @@ func processStates(m *Model, states []map[*Variable]bool, aux bool) States {
 	return result
 }
 
-// Convert states to tabular data.
-func convertStatesToTable(states States) [][]string {
-	table := make([][]string, 1, len(states)+1)
-
-	// Assume each state has the same variables. Note that we sort the boolean and
-	// integer names separately to align with States.Less.
-	st0 := states[0]
-	names := make([]string, 0, len(st0.bools)+len(st0.ints))
-	intNames := make([]string, 0, len(st0.ints))
-	for name := range st0.bools {
+// Names returns the variable names of the state. Booleans, integers and enums
+// are sorted separately (in that order) to align with States.Less.
+func (s *State) Names() []string {
+	names := make([]string, 0, len(s.bools)+len(s.ints)+len(s.enums))
+	intNames := make([]string, 0, len(s.ints))
+	enumNames := make([]string, 0, len(s.enums))
+	for name := range s.bools {
 		names = append(names, name)
 	}
-	for name := range st0.ints {
+	for name := range s.ints {
 		intNames = append(intNames, name)
 	}
+	for name := range s.enums {
+		enumNames = append(enumNames, name)
+	}
 	sort.Sort(ByStringLt(names))
 	sort.Sort(ByStringLt(intNames))
+	sort.Sort(ByStringLt(enumNames))
 	names = append(names, intNames...)
+	return append(names, enumNames...)
+}
+
+// Value returns the value of a variable as a string.
+func (s *State) Value(name string) string {
+	if b, in := s.bools[name]; in {
+		return fmt.Sprintf("%v", b)
+	} else if i, in := s.ints[name]; in {
+		return fmt.Sprintf("%v", i)
+	}
+	return s.enums[name]
+}
+
+// Get the values of all variables.
+func (s *State) values() map[string]interface{} {
+	values := make(map[string]interface{}, len(s.bools)+len(s.ints)+len(s.enums))
+	for name, b := range s.bools {
+		values[name] = b
+	}
+	for name, i := range s.ints {
+		values[name] = i
+	}
+	for name, e := range s.enums {
+		values[name] = e
+	}
+	return values
+}
+
+// Bool returns the value of a boolean variable.
+func (s *State) Bool(name string) bool {
+	return s.bools[name]
+}
+
+// Int returns the value of an integer variable.
+func (s *State) Int(name string) int {
+	return s.ints[name]
+}
+
+// Enum returns the value of an enum variable.
+func (s *State) Enum(name string) string {
+	return s.enums[name]
+}
+
+// String formats the state as a list of assignments.
+func (s *State) String() string {
+	names := s.Names()
+	assignments := make([]string, len(names))
+	for i, name := range names {
+		assignments[i] = fmt.Sprintf("%v=%v", name, s.Value(name))
+	}
+	return strings.Join(assignments, ", ")
+}
+
+// Convert states to tabular data.
+func convertStatesToTable(states States) [][]string {
+	table := make([][]string, 1, len(states)+1)
+
+	// Assume each state has the same variables.
+	names := states[0].Names()
 
 	// Extract values from each state.
 	table[0] = names
 	for _, state := range states {
 		values := make([]string, len(names))
 		for i, name := range names {
-			if i < len(st0.bools) {
-				values[i] = fmt.Sprintf("%v", state.bools[name])
-			} else {
-				values[i] = fmt.Sprintf("%v", state.ints[name])
-			}
+			values[i] = state.Value(name)
 		}
 		table = append(table, values)
 	}
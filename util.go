@@ -18,6 +18,15 @@ func bitcount(i uint) uint {
 	return uint(math.Floor(math.Log2(float64(i)) + 1))
 }
 
+// Number of bits required to represent lo..hi in two's complement.
+func signedBitcount(lo int, hi int) uint {
+	n := uint(1)
+	for lo < -(1<<(n-1)) || hi > (1<<(n-1))-1 {
+		n++
+	}
+	return n
+}
+
 // ByStringLt defines a sort interface for ordinary string sorting.
 type ByStringLt []string
 